@@ -0,0 +1,218 @@
+// Package testlib provides fake Kubernetes/OpenShift objects and clients
+// shared by the vspherecontroller and operand test suites, so each test file
+// doesn't have to hand-roll its own fixtures.
+package testlib
+
+import (
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	fakeoperator "github.com/openshift/client-go/operator/clientset/versioned/fake"
+	operatorinformers "github.com/openshift/client-go/operator/informers/externalversions"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	fakecore "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	DefaultNamespace     = "openshift-cluster-csi-drivers"
+	CloudConfigNamespace = "openshift-config"
+	configMapName        = "vsphere-csi-config"
+	secretName           = "vmware-vsphere-cloud-credentials"
+	csiDriverName        = "csi.vsphere.vmware.com"
+)
+
+// FakeDriverInstance is the fake ClusterCSIDriver operator resource the
+// controller reconciles against in tests.
+type FakeDriverInstance struct {
+	opv1.ClusterCSIDriver
+}
+
+// MakeFakeDriverInstance returns a minimal, valid ClusterCSIDriver and
+// applies any modifier functions on top of it, in order.
+func MakeFakeDriverInstance(modifiers ...func(*FakeDriverInstance) *FakeDriverInstance) *FakeDriverInstance {
+	instance := &FakeDriverInstance{
+		ClusterCSIDriver: opv1.ClusterCSIDriver{
+			ObjectMeta: metav1.ObjectMeta{Name: csiDriverName},
+			Spec: opv1.ClusterCSIDriverSpec{
+				OperatorSpec: opv1.OperatorSpec{
+					ManagementState: opv1.Managed,
+				},
+			},
+		},
+	}
+	for _, m := range modifiers {
+		instance = m(instance)
+	}
+	return instance
+}
+
+func GetConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: CloudConfigNamespace},
+		Data: map[string]string{
+			"vsphere.conf": "[Global]\ninsecure-flag = true\n",
+		},
+	}
+}
+
+func GetSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: DefaultNamespace},
+		Data: map[string][]byte{
+			"username": []byte("administrator@vsphere.local"),
+			"password": []byte("fake"),
+		},
+	}
+}
+
+func GetInfraObject() *configv1.Infrastructure {
+	return &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: configv1.InfrastructureSpec{
+			PlatformSpec: configv1.PlatformSpec{
+				Type: configv1.VSpherePlatformType,
+			},
+		},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.VSpherePlatformType,
+			},
+		},
+	}
+}
+
+func GetCSIDriver(withOCPAnnotation bool) *storagev1.CSIDriver {
+	driver := &storagev1.CSIDriver{
+		ObjectMeta: metav1.ObjectMeta{Name: csiDriverName},
+	}
+	if withOCPAnnotation {
+		driver.Annotations = map[string]string{"csi.openshift.io/managed": "true"}
+	}
+	return driver
+}
+
+func GetCSINode() *storagev1.CSINode {
+	return &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+		Spec: storagev1.CSINodeSpec{
+			Drivers: []storagev1.CSINodeDriver{
+				{Name: csiDriverName, NodeID: "node-0"},
+			},
+		},
+	}
+}
+
+// GetMatchingCondition returns the condition of the given type, or nil if
+// none is found.
+func GetMatchingCondition(conditions []opv1.OperatorCondition, conditionType string) *opv1.OperatorCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// GetTestClusterResult builds a ClusterCheckResult for the given status.
+// Every non-passing status gets CheckActionBlockUpgrade, whatever Action the
+// controller's own checks pair that status with.
+func GetTestClusterResult(status checks.CheckStatus) checks.ClusterCheckResult {
+	if status == checks.CheckStatusPass {
+		return checks.MakeClusterCheckResultPass()
+	}
+	return checks.ClusterCheckResult{
+		CheckStatus: status,
+		Action:      checks.CheckActionBlockUpgrade,
+		Reason:      string(status),
+	}
+}
+
+// NewFakeClients wires up fake Kubernetes/OpenShift clientsets and
+// informers seeded with the given objects, and returns the bundled
+// APIClient the controller under test is constructed from.
+func NewFakeClients(initialObjects []runtime.Object, driver *FakeDriverInstance, configObject runtime.Object) *utils.APIClient {
+	kubeClient := fakecore.NewSimpleClientset(initialObjects...)
+	configObjects := []runtime.Object{}
+	if configObject != nil {
+		configObjects = append(configObjects, configObject)
+	}
+	configClient := fakeconfig.NewSimpleClientset(configObjects...)
+	operatorObjectClient := fakeoperator.NewSimpleClientset(&driver.ClusterCSIDriver)
+
+	kubeInformers := v1helpers.NewKubeInformersForNamespaces(kubeClient, DefaultNamespace, CloudConfigNamespace, "")
+	configInformers := configinformers.NewSharedInformerFactory(configClient, 10*time.Minute)
+	operatorInformers := operatorinformers.NewSharedInformerFactory(operatorObjectClient, 10*time.Minute)
+
+	// Register and seed the Infrastructure and ClusterCSIDriver informers
+	// here, so StartFakeInformer starts them and their listers already hold
+	// the objects before the first sync.
+	if infra, ok := configObject.(*configv1.Infrastructure); ok {
+		_ = configInformers.Config().V1().Infrastructures().Informer().GetIndexer().Add(infra)
+	}
+	_ = operatorInformers.Operator().V1().ClusterCSIDrivers().Informer().GetIndexer().Add(&driver.ClusterCSIDriver)
+
+	operatorClient := v1helpers.NewFakeOperatorClient(
+		&driver.Spec.OperatorSpec,
+		&driver.Status.OperatorStatus,
+		nil,
+	)
+
+	return &utils.APIClient{
+		KubeClient:        kubeClient,
+		OperatorClient:    operatorClient,
+		ConfigInformers:   configInformers,
+		OperatorInformers: operatorInformers,
+		KubeInformers:     kubeInformers,
+		SecretInformer:    kubeInformers.InformersFor(DefaultNamespace).Core().V1().Secrets(),
+		NodeInformer:      informers.NewSharedInformerFactory(kubeClient, 10*time.Minute).Core().V1().Nodes(),
+	}
+}
+
+// StartFakeInformer starts every informer registered against apiClient. It
+// is expected to run in its own goroutine and exit when stopCh is closed.
+func StartFakeInformer(apiClient *utils.APIClient, stopCh <-chan struct{}) {
+	apiClient.KubeInformers.Start(stopCh)
+	apiClient.ConfigInformers.Start(stopCh)
+	apiClient.OperatorInformers.Start(stopCh)
+	apiClient.NodeInformer.Informer().Run(stopCh)
+}
+
+// AddInitialObjects seeds the fake informers' indexers directly, so tests
+// don't have to race the informer's own list/watch before the first sync.
+func AddInitialObjects(objects []runtime.Object, apiClient *utils.APIClient) error {
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *storagev1.CSIDriver:
+			if err := apiClient.KubeInformers.InformersFor("").Storage().V1().CSIDrivers().Informer().GetIndexer().Add(o); err != nil {
+				return err
+			}
+		case *storagev1.CSINode:
+			if err := apiClient.KubeInformers.InformersFor("").Storage().V1().CSINodes().Informer().GetIndexer().Add(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WaitForSync blocks until every fake informer has completed its initial
+// list, so sync() under test observes a fully-populated cache.
+func WaitForSync(apiClient *utils.APIClient, stopCh <-chan struct{}) {
+	for ns := range apiClient.KubeInformers.Namespaces() {
+		apiClient.KubeInformers.InformersFor(ns).WaitForCacheSync(stopCh)
+	}
+	apiClient.ConfigInformers.WaitForCacheSync(stopCh)
+	apiClient.OperatorInformers.WaitForCacheSync(stopCh)
+	cache.WaitForCacheSync(stopCh, apiClient.NodeInformer.Informer().HasSynced)
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// InstallErrorMetric records the last known reason the operator could not
+// install or upgrade the CSI driver, broken down by the high level
+// condition (install_blocked, upgrade_blocked, upgrade_unknown, ...), the
+// specific failure_reason that drove it, and the vcenter server the failure
+// came from (empty for checks that aren't tied to a single vCenter, or on a
+// legacy single-vCenter cluster). It is a gauge rather than a counter
+// because a given label combination should read as "currently true", not
+// accumulate across resyncs.
+var InstallErrorMetric = metrics.NewGaugeVec(&metrics.GaugeOpts{
+	Name: "vsphere_csi_driver_error",
+	Help: "vSphere driver installation error",
+}, []string{"condition", "failure_reason", "vcenter"})
+
+func init() {
+	legacyregistry.MustRegister(InstallErrorMetric)
+}
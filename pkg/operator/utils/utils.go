@@ -0,0 +1,23 @@
+package utils
+
+import (
+	configv1informers "github.com/openshift/client-go/config/informers/externalversions"
+	operatorv1informers "github.com/openshift/client-go/operator/informers/externalversions"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// APIClient bundles the clients and informers the vSphere controllers need
+// in order to talk to the management cluster and watch its platform
+// configuration. It is passed around rather than threading individual
+// clients/listers through every constructor.
+type APIClient struct {
+	KubeClient        kubernetes.Interface
+	OperatorClient    v1helpers.OperatorClient
+	ConfigInformers   configv1informers.SharedInformerFactory
+	OperatorInformers operatorv1informers.SharedInformerFactory
+	KubeInformers     v1helpers.KubeInformersForNamespaces
+	SecretInformer    corev1informers.SecretInformer
+	NodeInformer      corev1informers.NodeInformer
+}
@@ -0,0 +1,97 @@
+package vclib
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+)
+
+// VSphereConnections holds one authenticated connection per vCenter server,
+// keyed by Infrastructure.spec.platformSpec.vsphere.vcenters[].server (or a
+// single blank-keyed entry on a legacy single-vCenter cluster). Checks fan
+// out across every entry and the operand only starts once all of them pass.
+type VSphereConnections map[string]*VSphereConnection
+
+// Logout closes every connection in the set. It is used to unwind a partial
+// multi-vCenter connection attempt once one of the later vCenters fails.
+func (c VSphereConnections) Logout(ctx context.Context) {
+	for _, conn := range c {
+		conn.Logout(ctx)
+	}
+}
+
+// VSphereConnection wraps an authenticated govmomi client for a single
+// vCenter server together with the finder used to resolve inventory
+// objects (datacenters, clusters, datastores, hosts) by path.
+type VSphereConnection struct {
+	// Server is the vCenter host this connection talks to, e.g.
+	// "vcenter.example.com". On a multi-vCenter cluster it is also this
+	// connection's key in VSphereConnections, which becomes the "vcenter"
+	// metric label; on a legacy single-vCenter cluster that key is "".
+	Server string
+	Client *govmomi.Client
+	Finder *find.Finder
+}
+
+// Logout closes the underlying session. Callers should defer this right
+// after a successful connection is established.
+func (c *VSphereConnection) Logout(ctx context.Context) {
+	if c == nil || c.Client == nil {
+		return
+	}
+	_ = c.Client.Logout(ctx)
+}
+
+// DatacenterExists implements checks.InventoryLookup.
+func (c *VSphereConnection) DatacenterExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.Finder.Datacenter(ctx, path)
+	return err == nil, err
+}
+
+// ComputeClusterExists implements checks.InventoryLookup.
+func (c *VSphereConnection) ComputeClusterExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.Finder.ClusterComputeResource(ctx, path)
+	return err == nil, err
+}
+
+// DatastoreExists implements checks.InventoryLookup.
+func (c *VSphereConnection) DatastoreExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.Finder.Datastore(ctx, path)
+	return err == nil, err
+}
+
+// HasTag reports whether the datacenter at objectPath carries a tag named
+// tag in category. It satisfies checks.TagLookup structurally so callers
+// can pass a *VSphereConnection directly as CheckArgs.Tags.
+func (c *VSphereConnection) HasTag(ctx context.Context, objectPath, category, tag string) (bool, error) {
+	dc, err := c.Finder.Datacenter(ctx, objectPath)
+	if err != nil {
+		return false, err
+	}
+
+	restClient := rest.NewClient(c.Client.Client)
+	if err := restClient.Login(ctx, c.Client.Client.Client.URL().User); err != nil {
+		return false, err
+	}
+	defer restClient.Logout(ctx)
+
+	tagMgr := tags.NewManager(restClient)
+	attached, err := tagMgr.GetAttachedTags(ctx, dc)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range attached {
+		tagCategory, err := tagMgr.GetCategory(ctx, t.CategoryID)
+		if err != nil {
+			continue
+		}
+		if tagCategory.Name == category && t.Name == tag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
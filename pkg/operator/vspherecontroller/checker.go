@@ -0,0 +1,86 @@
+package vspherecontroller
+
+import (
+	"context"
+	"time"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+)
+
+// defaultRecheckInterval is how often vCenter-side state (version, hardware
+// compatibility) is re-verified when nothing has told the controller it
+// needs to happen sooner.
+const defaultRecheckInterval = 5 * time.Minute
+
+// vSphereEnvironmentCheckInterface abstracts environment verification so
+// tests can substitute a no-op implementation without standing up a real
+// check pipeline.
+type vSphereEnvironmentCheckInterface interface {
+	// Check runs every registered environment check against args and
+	// returns the duration to wait before the next check is due, the
+	// aggregate result, and whether that result is a verdict: false when the
+	// always-run checks passed but the rate-limited ones were not due yet,
+	// in which case the result is empty.
+	Check(ctx context.Context, args checks.CheckArgs) (time.Duration, checks.ClusterCheckResult, bool)
+}
+
+// vSphereEnvironmentCheckerComposite runs two tiers of checks in order and
+// stops at the first one that doesn't pass. alwaysCheckers only read
+// already-cached Kubernetes state (CSIDriver/CSINode), so they run on every
+// call: the controller is resynced immediately whenever that state changes
+// (see NewVSphereController's informers), and there's no cost to
+// re-verifying it every time. rateLimitedCheckers talk to vCenter itself
+// (including topology, which walks inventory and tags through
+// vclib.VSphereConnection), so they're only re-verified once the recheck
+// interval has elapsed - otherwise the same CSIDriver/CSINode/StorageClass
+// informers that make alwaysCheckers cheap to re-run would instead cause
+// topology to re-authenticate against vCenter's REST API on every one of
+// those (fairly chatty) object changes.
+type vSphereEnvironmentCheckerComposite struct {
+	alwaysCheckers      []checkRunner
+	rateLimitedCheckers []checkRunner
+	nextCheck           time.Time
+}
+
+// checkRunner is a single named environment check.
+type checkRunner struct {
+	name string
+	run  func(ctx context.Context, args checks.CheckArgs) checks.ClusterCheckResult
+}
+
+func newVSphereEnvironmentChecker() *vSphereEnvironmentCheckerComposite {
+	return &vSphereEnvironmentCheckerComposite{
+		alwaysCheckers: []checkRunner{
+			{name: "existing-driver", run: checkExistingDriver},
+		},
+		rateLimitedCheckers: []checkRunner{
+			{name: "vcenter-api-version", run: checks.CheckVCenterAPIVersion},
+			{name: "hardware-version", run: checkNodeHardwareVersions},
+			{name: "topology", run: checks.CheckTopology},
+		},
+	}
+}
+
+func (c *vSphereEnvironmentCheckerComposite) Check(ctx context.Context, args checks.CheckArgs) (time.Duration, checks.ClusterCheckResult, bool) {
+	for _, checker := range c.alwaysCheckers {
+		result := checker.run(ctx, args)
+		if result.Action != checks.CheckActionPass {
+			return 0, result, true
+		}
+	}
+
+	if time.Now().Before(c.nextCheck) {
+		return time.Until(c.nextCheck), checks.ClusterCheckResult{}, false
+	}
+
+	for _, checker := range c.rateLimitedCheckers {
+		result := checker.run(ctx, args)
+		if result.Action != checks.CheckActionPass {
+			c.nextCheck = time.Now().Add(defaultRecheckInterval)
+			return defaultRecheckInterval, result, true
+		}
+	}
+
+	c.nextCheck = time.Now().Add(defaultRecheckInterval)
+	return defaultRecheckInterval, checks.MakeClusterCheckResultPass(), true
+}
@@ -0,0 +1,56 @@
+package checks
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+)
+
+// perConnectionCheck is a check that runs against a single vCenter
+// connection.
+type perConnectionCheck func(ctx context.Context, server string, conn *vclib.VSphereConnection) ClusterCheckResult
+
+// checkAllConnections runs check against every entry of args.Connections
+// concurrently and aggregates the results. If every connection passes it
+// returns a single pass result; otherwise it returns the failure for the
+// lowest-sorted server name among the failures, so the result is
+// deterministic regardless of goroutine scheduling. Every returned failure
+// has its VCenter field set to the server it came from.
+func checkAllConnections(ctx context.Context, args CheckArgs, check perConnectionCheck) ClusterCheckResult {
+	if len(args.Connections) == 0 {
+		return MakeClusterCheckResultPass()
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[string]ClusterCheckResult, len(args.Connections))
+
+	for server, conn := range args.Connections {
+		wg.Add(1)
+		go func(server string, conn *vclib.VSphereConnection) {
+			defer wg.Done()
+			result := check(ctx, server, conn)
+			result.VCenter = server
+
+			mu.Lock()
+			results[server] = result
+			mu.Unlock()
+		}(server, conn)
+	}
+	wg.Wait()
+
+	servers := make([]string, 0, len(results))
+	for server := range results {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	for _, server := range servers {
+		if result := results[server]; result.Action != CheckActionPass {
+			return result
+		}
+	}
+	return MakeClusterCheckResultPass()
+}
@@ -0,0 +1,82 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// OpenshiftRegionCategory and OpenshiftZoneCategory are the vSphere tag
+	// categories the installer creates on the datacenter/cluster object of
+	// each failure domain so the CSI driver and scheduler can agree on
+	// topology.
+	OpenshiftRegionCategory = "openshift-region"
+	OpenshiftZoneCategory   = "openshift-zone"
+)
+
+// CheckTopology verifies that every failure domain declared on
+// Infrastructure.spec.platformSpec.vsphere.failureDomains points at
+// inventory that exists in vCenter and is tagged with the
+// openshift-region/openshift-zone categories the installer is expected to
+// have created. It is a no-op on clusters that don't declare failure
+// domains (the single-zone default).
+func CheckTopology(ctx context.Context, args CheckArgs) ClusterCheckResult {
+	if len(args.FailureDomains) == 0 {
+		return MakeClusterCheckResultPass()
+	}
+
+	for _, fd := range args.FailureDomains {
+		// fd.Server picks which connected vCenter's inventory/tags this
+		// failure domain is checked against; it is blank on a legacy
+		// single-vCenter cluster, matching the "" key Connections/Tags/
+		// Inventory use in that case. connectToVCenters only ever returns a
+		// result once every vcenters entry has connected, so a miss here
+		// means fd.Server is a stale/typo'd reference to a vCenter that
+		// isn't actually part of the cluster - a misconfiguration, not a
+		// pass.
+		inventory := args.Inventory[fd.Server]
+		if inventory == nil {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("server %q is not a connected vCenter", fd.Server))
+		}
+
+		if ok, err := inventory.DatacenterExists(ctx, fd.Topology.Datacenter); err != nil || !ok {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("datacenter %q not found: %v", fd.Topology.Datacenter, err))
+		}
+		if fd.Topology.ComputeCluster != "" {
+			if ok, err := inventory.ComputeClusterExists(ctx, fd.Topology.ComputeCluster); err != nil || !ok {
+				return topologyMisconfigured(fd.Name, fmt.Sprintf("compute cluster %q not found: %v", fd.Topology.ComputeCluster, err))
+			}
+		}
+		if fd.Topology.Datastore != "" {
+			if ok, err := inventory.DatastoreExists(ctx, fd.Topology.Datastore); err != nil || !ok {
+				return topologyMisconfigured(fd.Name, fmt.Sprintf("datastore %q not found: %v", fd.Topology.Datastore, err))
+			}
+		}
+
+		tags := args.Tags[fd.Server]
+		if tags == nil {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("server %q is not a connected vCenter", fd.Server))
+		}
+		hasRegion, err := tags.HasTag(ctx, fd.Topology.Datacenter, OpenshiftRegionCategory, fd.Region)
+		if err != nil {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("failed to look up %s tag: %v", OpenshiftRegionCategory, err))
+		}
+		hasZone, err := tags.HasTag(ctx, fd.Topology.Datacenter, OpenshiftZoneCategory, fd.Zone)
+		if err != nil {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("failed to look up %s tag: %v", OpenshiftZoneCategory, err))
+		}
+		if !hasRegion || !hasZone {
+			return topologyMisconfigured(fd.Name, fmt.Sprintf("datacenter %q is missing the %s/%s tags expected by region %q / zone %q", fd.Topology.Datacenter, OpenshiftRegionCategory, OpenshiftZoneCategory, fd.Region, fd.Zone))
+		}
+	}
+
+	return MakeClusterCheckResultPass()
+}
+
+func topologyMisconfigured(failureDomain, reason string) ClusterCheckResult {
+	return ClusterCheckResult{
+		CheckStatus: CheckStatusTopologyMisconfigured,
+		Action:      CheckActionBlockInstall,
+		Reason:      fmt.Sprintf("failure domain %q is misconfigured: %s", failureDomain, reason),
+	}
+}
@@ -0,0 +1,132 @@
+package checks
+
+import (
+	"context"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+type fakeTagLookup struct {
+	// tags maps objectPath -> category -> tag name actually attached.
+	tags map[string]map[string]string
+}
+
+func (f *fakeTagLookup) HasTag(_ context.Context, objectPath, category, tag string) (bool, error) {
+	byCategory, ok := f.tags[objectPath]
+	if !ok {
+		return false, nil
+	}
+	return byCategory[category] == tag, nil
+}
+
+// fakeInventory treats every path as an existing datacenter/compute
+// cluster/datastore, so these cases exercise the server lookup and the
+// tag-matching branch of CheckTopology; the existence checks are not
+// exercised here.
+type fakeInventory struct{}
+
+func (fakeInventory) DatacenterExists(context.Context, string) (bool, error)     { return true, nil }
+func (fakeInventory) ComputeClusterExists(context.Context, string) (bool, error) { return true, nil }
+func (fakeInventory) DatastoreExists(context.Context, string) (bool, error)      { return true, nil }
+
+func failureDomain(name, region, zone, datacenter string) configv1.VSpherePlatformFailureDomainSpec {
+	return configv1.VSpherePlatformFailureDomainSpec{
+		Name:   name,
+		Region: region,
+		Zone:   zone,
+		Topology: configv1.VSpherePlatformTopology{
+			Datacenter: datacenter,
+		},
+	}
+}
+
+func failureDomainWithServer(name, region, zone, datacenter, server string) configv1.VSpherePlatformFailureDomainSpec {
+	fd := failureDomain(name, region, zone, datacenter)
+	fd.Server = server
+	return fd
+}
+
+func TestCheckTopology(t *testing.T) {
+	tests := []struct {
+		name string
+		// connectedServers are the vCenters CheckTopology is called as having
+		// successfully connected to; it defaults to {""}, the legacy
+		// single-vCenter key, when left nil.
+		connectedServers []string
+		failureDomains   []configv1.VSpherePlatformFailureDomainSpec
+		tags             map[string]map[string]string
+		expectAction     CheckAction
+	}{
+		{
+			name: "single zone, correctly tagged",
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				failureDomain("us-east-1a", "us-east", "us-east-1a", "/DC0"),
+			},
+			tags: map[string]map[string]string{
+				"/DC0": {OpenshiftRegionCategory: "us-east", OpenshiftZoneCategory: "us-east-1a"},
+			},
+			expectAction: CheckActionPass,
+		},
+		{
+			name: "multi zone, all correctly tagged",
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				failureDomain("us-east-1a", "us-east", "us-east-1a", "/DC0"),
+				failureDomain("us-east-1b", "us-east", "us-east-1b", "/DC1"),
+			},
+			tags: map[string]map[string]string{
+				"/DC0": {OpenshiftRegionCategory: "us-east", OpenshiftZoneCategory: "us-east-1a"},
+				"/DC1": {OpenshiftRegionCategory: "us-east", OpenshiftZoneCategory: "us-east-1b"},
+			},
+			expectAction: CheckActionPass,
+		},
+		{
+			name: "zone tag missing on second failure domain",
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				failureDomain("us-east-1a", "us-east", "us-east-1a", "/DC0"),
+				failureDomain("us-east-1b", "us-east", "us-east-1b", "/DC1"),
+			},
+			tags: map[string]map[string]string{
+				"/DC0": {OpenshiftRegionCategory: "us-east", OpenshiftZoneCategory: "us-east-1a"},
+				"/DC1": {OpenshiftRegionCategory: "us-east"},
+			},
+			expectAction: CheckActionBlockInstall,
+		},
+		{
+			name: "failure domain references a vCenter that isn't connected",
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				failureDomainWithServer("us-east-1a", "us-east", "us-east-1a", "/DC0", "vcenter.stale.example.com"),
+			},
+			tags: map[string]map[string]string{
+				"/DC0": {OpenshiftRegionCategory: "us-east", OpenshiftZoneCategory: "us-east-1a"},
+			},
+			expectAction: CheckActionBlockInstall,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			servers := test.connectedServers
+			if servers == nil {
+				servers = []string{""}
+			}
+			tagLookups := map[string]TagLookup{}
+			inventories := map[string]InventoryLookup{}
+			for _, server := range servers {
+				tagLookups[server] = &fakeTagLookup{tags: test.tags}
+				inventories[server] = fakeInventory{}
+			}
+
+			result := CheckTopology(context.TODO(), CheckArgs{
+				FailureDomains: test.failureDomains,
+				Tags:           tagLookups,
+				Inventory:      inventories,
+			})
+
+			if result.Action != test.expectAction {
+				t.Fatalf("expected action %q, got %q (reason: %s)", test.expectAction, result.Action, result.Reason)
+			}
+		})
+	}
+}
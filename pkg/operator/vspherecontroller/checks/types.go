@@ -0,0 +1,152 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+)
+
+// CheckStatus identifies a specific outcome an environment check can reach.
+// It doubles as the Reason on the OperatorCondition the controller sets, so
+// values are kept short, CamelCase, and stable across releases.
+type CheckStatus string
+
+const (
+	CheckStatusPass                    CheckStatus = "Pass"
+	CheckStatusVSphereConnectionFailed CheckStatus = "VSphereConnectionFailed"
+	CheckStatusDeprecatedVCenter       CheckStatus = "CheckDeprecatedVCenter"
+	CheckStatusDeprecatedHWVersion     CheckStatus = "CheckDeprecatedHWVersion"
+	CheckStatusExistingDriverFound     CheckStatus = "ExistingDriverFound"
+	CheckStatusOpenshiftAPIError       CheckStatus = "OpenshiftAPIError"
+	CheckStatusGenericError            CheckStatus = "GenericError"
+	// CheckStatusTopologyMisconfigured means a declared failure domain's
+	// datacenter/cluster/datastore is missing, unreachable, or not tagged
+	// with the openshift-zone/openshift-region categories the installer is
+	// expected to have created.
+	CheckStatusTopologyMisconfigured CheckStatus = "TopologyMisconfigured"
+)
+
+// CheckAction tells the controller what to do with the cluster operator
+// conditions once a check produces a non-pass ClusterCheckResult.
+type CheckAction string
+
+const (
+	// CheckActionPass means the check succeeded, no condition changes are needed.
+	CheckActionPass CheckAction = "pass"
+	// CheckActionBlockUpgrade flips Upgradeable to False (Unknown for a
+	// vCenter connection failure) while the operand hasn't started. Once the
+	// operand is running it degrades the cluster instead; a vCenter
+	// connection failure only does so after repeated consecutive failures
+	// (see VSphereController.recordConnectionFailure).
+	CheckActionBlockUpgrade CheckAction = "block_upgrade"
+	// CheckActionBlockInstall prevents the operand controllers from starting
+	// at all but leaves the cluster Available.
+	CheckActionBlockInstall CheckAction = "block_install"
+	// CheckActionDegrade marks the operator Degraded, used when the operand
+	// is already running and can no longer be reconciled safely.
+	CheckActionDegrade CheckAction = "degrade"
+)
+
+// ClusterCheckResult is the outcome of running one or more environment
+// checks against a vCenter connection. MakeClusterCheckResultPass builds the
+// result that means every check passed; the zero value has an empty Action
+// and is not treated as a pass.
+type ClusterCheckResult struct {
+	CheckError  error
+	CheckStatus CheckStatus
+	Action      CheckAction
+	Reason      string
+	// VCenter is the server a per-connection check failed against, e.g.
+	// "vcenter.example.com". Empty for checks that aren't tied to a single
+	// vCenter (existing-driver, topology) or on a legacy single-vCenter
+	// cluster, where it is also the metric label value.
+	VCenter string
+}
+
+// MakeClusterCheckResultPass returns the canonical "all checks passed" result.
+func MakeClusterCheckResultPass() ClusterCheckResult {
+	return ClusterCheckResult{
+		CheckStatus: CheckStatusPass,
+		Action:      CheckActionPass,
+	}
+}
+
+// MakeConnectionFailedResult wraps an error establishing a vCenter
+// connection itself (as opposed to a check failing once connected) into the
+// result VSphereController.handleCheckResult recognizes as transient (see
+// recordConnectionFailure), so a flapping vCenter only degrades the cluster after it has failed
+// connectionFailureThreshold times in a row.
+func MakeConnectionFailedResult(err error) ClusterCheckResult {
+	return ClusterCheckResult{
+		CheckError:  err,
+		CheckStatus: CheckStatusVSphereConnectionFailed,
+		Action:      CheckActionBlockUpgrade,
+		Reason:      fmt.Sprintf("Failed to connect to vSphere: %v", err),
+	}
+}
+
+// MakeGenericVCenterAPIError wraps an arbitrary vCenter API error (one that
+// does not map to a more specific CheckStatus) into a result that blocks
+// upgrades; like any CheckActionBlockUpgrade result, it degrades a cluster
+// that is already running the operand.
+func MakeGenericVCenterAPIError(err error) ClusterCheckResult {
+	return ClusterCheckResult{
+		CheckError:  err,
+		CheckStatus: CheckStatusGenericError,
+		Action:      CheckActionBlockUpgrade,
+		Reason:      err.Error(),
+	}
+}
+
+// CheckArgs bundles everything an individual check function needs: the
+// vCenter connection(s) to run against and the Kubernetes-side state
+// (nodes, existing CSIDriver/CSINode objects, infrastructure config) that
+// some checks correlate against vCenter inventory.
+type CheckArgs struct {
+	// Connections holds one entry per connected vCenter, keyed by server
+	// (Infrastructure.spec.platformSpec.vsphere.vcenters[].server), or a
+	// single ""-keyed entry on a legacy single-vCenter cluster. Checks that
+	// talk to vCenter fan out across every entry via checkAllConnections.
+	Connections vclib.VSphereConnections
+	// Nodes is the set of compute nodes whose hardware version is checked
+	// against the minimum the CSI driver supports.
+	Nodes []*corev1.Node
+	// ExistingCSIDriverFound and ExistingCSINodeFound are true when an
+	// upstream csi.vsphere.vmware.com CSIDriver/CSINode object was found
+	// that the operator did not install itself.
+	ExistingCSIDriverFound bool
+	ExistingCSINodeFound   bool
+	// FailureDomains is Infrastructure.spec.platformSpec.vsphere.failureDomains,
+	// consumed by CheckTopology to verify every declared zone/region actually
+	// exists and is tagged in vCenter. Each domain's Server field picks the
+	// entry of Tags/Inventory it is checked against.
+	FailureDomains []configv1.VSpherePlatformFailureDomainSpec
+	// Tags answers openshift-zone/openshift-region tag lookups against each
+	// connected vCenter, keyed by server the same way Connections is.
+	// Production wiring goes through govmomi's vapi/tags client; tests
+	// substitute a fake.
+	Tags map[string]TagLookup
+	// Inventory resolves the datacenter/cluster/datastore paths a failure
+	// domain names, keyed by server the same way Connections is. Production
+	// wiring goes through the connection's govmomi Finder; tests substitute
+	// a fake.
+	Inventory map[string]InventoryLookup
+}
+
+// TagLookup answers whether the vSphere inventory object at path carries
+// the given tag category/name, e.g. ("/DC0", "openshift-zone", "us-east-1a").
+type TagLookup interface {
+	HasTag(ctx context.Context, objectPath, category, tag string) (bool, error)
+}
+
+// InventoryLookup resolves vCenter inventory paths, used to verify a
+// failure domain's datacenter/cluster/datastore actually exist.
+type InventoryLookup interface {
+	DatacenterExists(ctx context.Context, path string) (bool, error)
+	ComputeClusterExists(ctx context.Context, path string) (bool, error)
+	DatastoreExists(ctx context.Context, path string) (bool, error)
+}
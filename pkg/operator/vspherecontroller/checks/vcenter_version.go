@@ -0,0 +1,44 @@
+package checks
+
+import (
+	"context"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+)
+
+// minSupportedVCenterVersion is the oldest vCenter API version the CSI
+// driver supports. Anything older blocks upgrades until the admin updates
+// vCenter, and degrades a cluster that is already running the operand.
+const minSupportedVCenterVersion = "6.7.3"
+
+// CheckVCenterAPIVersion verifies that every connected vCenter reports an
+// API version at least minSupportedVCenterVersion. On a multi-vCenter
+// cluster a single deprecated vCenter blocks the upgrade even if the others
+// are current.
+func CheckVCenterAPIVersion(ctx context.Context, args CheckArgs) ClusterCheckResult {
+	return checkAllConnections(ctx, args, func(ctx context.Context, server string, conn *vclib.VSphereConnection) ClusterCheckResult {
+		about := conn.Client.ServiceContent.About
+		if about.ApiVersion < minSupportedVCenterVersion {
+			return ClusterCheckResult{
+				CheckStatus: CheckStatusDeprecatedVCenter,
+				Action:      CheckActionBlockUpgrade,
+				Reason:      "found older vcenter version, expected is " + minSupportedVCenterVersion,
+				CheckError:  &vCenterVersionError{server: server, found: about.ApiVersion, expected: minSupportedVCenterVersion},
+			}
+		}
+		return MakeClusterCheckResultPass()
+	})
+}
+
+// vCenterVersionError implements error so it can be carried in
+// ClusterCheckResult.CheckError and returned by sync() as the degrading
+// error once the operand is running.
+type vCenterVersionError struct {
+	server   string
+	found    string
+	expected string
+}
+
+func (e *vCenterVersionError) Error() string {
+	return "vcenter " + e.server + " has version " + e.found + ", expected at least " + e.expected
+}
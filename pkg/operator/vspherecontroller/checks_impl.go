@@ -0,0 +1,39 @@
+package vspherecontroller
+
+import (
+	"context"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+)
+
+// minSupportedHWVersion is the oldest virtual hardware version CSI snapshots
+// and online volume expansion require.
+const minSupportedHWVersion = "vmx-15"
+
+func checkNodeHardwareVersions(ctx context.Context, args checks.CheckArgs) checks.ClusterCheckResult {
+	for _, node := range args.Nodes {
+		version, ok := node.Annotations[hardwareVersionAnnotation]
+		if !ok {
+			continue
+		}
+		if version < minSupportedHWVersion {
+			return checks.ClusterCheckResult{
+				CheckStatus: checks.CheckStatusDeprecatedHWVersion,
+				Action:      checks.CheckActionBlockUpgrade,
+				Reason:      "node " + node.Name + " has hardware version " + version + ", expected at least " + minSupportedHWVersion,
+			}
+		}
+	}
+	return checks.MakeClusterCheckResultPass()
+}
+
+func checkExistingDriver(ctx context.Context, args checks.CheckArgs) checks.ClusterCheckResult {
+	if args.ExistingCSIDriverFound || args.ExistingCSINodeFound {
+		return checks.ClusterCheckResult{
+			CheckStatus: checks.CheckStatusExistingDriverFound,
+			Action:      checks.CheckActionBlockInstall,
+			Reason:      "found an existing csi.vsphere.vmware.com CSIDriver or CSINode object that the operator did not install",
+		}
+	}
+	return checks.MakeClusterCheckResultPass()
+}
@@ -0,0 +1,178 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"gopkg.in/ini.v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	csiConfigMapName = "vsphere-csi-config"
+	csiConfigMapKey  = "cloud.conf"
+
+	snapshotSectionName = "Snapshot"
+
+	minGlobalMaxSnapshots   = 1
+	maxGlobalMaxSnapshots   = 32
+	minGranularMaxSnapshots = 1
+	maxGranularMaxSnapshots = 1024
+
+	invalidSnapshotConfigReason = "invalid_snapshot_config"
+
+	csiDriverControllerDeploymentName = "vmware-vsphere-csi-driver-controller"
+	// snapshotConfigRolloutAnnotation is stamped onto the controller
+	// Deployment's pod template whenever the rendered [Snapshot] section
+	// changes, forcing a rollout so the new limits take effect without
+	// waiting on the kubelet's own (eventually consistent) ConfigMap sync.
+	snapshotConfigRolloutAnnotation = "operator.openshift.io/vsphere-snapshot-config-hash"
+)
+
+// syncDriverConfig renders the admin-configurable snapshot limits from
+// ClusterCSIDriver.Spec.DriverConfig.VSphere into the [Snapshot] section of
+// the vsphere-csi-config ConfigMap, creating the section if it doesn't
+// exist yet. Out-of-range values are rejected rather than clamped: VMware
+// enforces the same bounds server-side, so silently clamping would leave
+// the admin's request and the rendered config permanently out of sync.
+func (c *VSphereController) syncDriverConfig(ctx context.Context, spec *opv1.ClusterCSIDriverSpec) error {
+	vsphereConfig := spec.DriverConfig.VSphere
+	if vsphereConfig == nil {
+		utils.InstallErrorMetric.DeleteLabelValues("degraded", invalidSnapshotConfigReason, "")
+		return nil
+	}
+
+	if err := validateSnapshotConfig(vsphereConfig); err != nil {
+		utils.InstallErrorMetric.WithLabelValues("degraded", invalidSnapshotConfigReason, "").Set(1)
+		return c.degradeWithReason(ctx, invalidSnapshotConfigReason, err.Error())
+	}
+	utils.InstallErrorMetric.DeleteLabelValues("degraded", invalidSnapshotConfigReason, "")
+
+	cm, err := c.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(ctx, csiConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// The operand hasn't rendered the base ConfigMap yet; it will pick
+		// up the snapshot section on its own first sync.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, changed, err := applySnapshotConfig(cm, vsphereConfig)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, err := c.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	return c.rollDeployment(ctx)
+}
+
+// validateSnapshotConfig checks every set field against the limits VMware
+// documents for CSI snapshotting: 1-32 snapshots per volume globally, and
+// 1-1024 per volume on the array types (vSAN, VVOL) that support more.
+func validateSnapshotConfig(cfg *opv1.VSphereCSIDriverConfigSpec) error {
+	if v := cfg.GlobalMaxSnapshotsPerBlockVolume; v != nil && (*v < minGlobalMaxSnapshots || *v > maxGlobalMaxSnapshots) {
+		return fmt.Errorf("globalMaxSnapshotsPerBlockVolume must be between %d and %d, got %d", minGlobalMaxSnapshots, maxGlobalMaxSnapshots, *v)
+	}
+	if v := cfg.GranularMaxSnapshotsPerBlockVolumeInVSAN; v != nil && (*v < minGranularMaxSnapshots || *v > maxGranularMaxSnapshots) {
+		return fmt.Errorf("granularMaxSnapshotsPerBlockVolumeInVSAN must be between %d and %d, got %d", minGranularMaxSnapshots, maxGranularMaxSnapshots, *v)
+	}
+	if v := cfg.GranularMaxSnapshotsPerBlockVolumeInVVOL; v != nil && (*v < minGranularMaxSnapshots || *v > maxGranularMaxSnapshots) {
+		return fmt.Errorf("granularMaxSnapshotsPerBlockVolumeInVVOL must be between %d and %d, got %d", minGranularMaxSnapshots, maxGranularMaxSnapshots, *v)
+	}
+	return nil
+}
+
+// applySnapshotConfig renders cfg into the [Snapshot] section of cm's
+// cloud.conf and reports whether the section actually changed.
+func applySnapshotConfig(cm *corev1.ConfigMap, cfg *opv1.VSphereCSIDriverConfigSpec) (*corev1.ConfigMap, bool, error) {
+	file, err := ini.Load([]byte(cm.Data[csiConfigMapKey]))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %w", csiConfigMapKey, err)
+	}
+
+	section := file.Section(snapshotSectionName)
+	before := section.KeysHash()
+
+	if v := cfg.GlobalMaxSnapshotsPerBlockVolume; v != nil {
+		section.Key("global-max-snapshots-per-block-volume").SetValue(fmt.Sprintf("%d", *v))
+	}
+	if v := cfg.GranularMaxSnapshotsPerBlockVolumeInVSAN; v != nil {
+		section.Key("granular-max-snapshots-per-block-volume-in-vsan").SetValue(fmt.Sprintf("%d", *v))
+	}
+	if v := cfg.GranularMaxSnapshotsPerBlockVolumeInVVOL; v != nil {
+		section.Key("granular-max-snapshots-per-block-volume-in-vvol").SetValue(fmt.Sprintf("%d", *v))
+	}
+
+	if keysEqual(before, section.KeysHash()) {
+		return cm, false, nil
+	}
+
+	var rendered strings.Builder
+	if _, err := file.WriteTo(&rendered); err != nil {
+		return nil, false, err
+	}
+
+	updated := cm.DeepCopy()
+	updated.Data[csiConfigMapKey] = rendered.String()
+	return updated, true, nil
+}
+
+func keysEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// rollDeployment forces a rollout of the CSI driver controller Deployment
+// by touching its pod template annotations.
+func (c *VSphereController) rollDeployment(ctx context.Context) error {
+	deployment, err := c.kubeClient.AppsV1().Deployments(defaultNamespace).Get(ctx, csiDriverControllerDeploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// Nothing to roll yet; the operand will render the config fresh on
+		// its first install.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	updated := deployment.DeepCopy()
+	if updated.Spec.Template.Annotations == nil {
+		updated.Spec.Template.Annotations = map[string]string{}
+	}
+	updated.Spec.Template.Annotations[snapshotConfigRolloutAnnotation] = updated.ResourceVersion
+
+	_, err = c.kubeClient.AppsV1().Deployments(defaultNamespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *VSphereController) degradeWithReason(ctx context.Context, reason, message string) error {
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+			Type:    c.name + opv1.OperatorStatusTypeDegraded,
+			Status:  opv1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		}),
+	)
+	return err
+}
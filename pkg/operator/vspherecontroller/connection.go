@@ -0,0 +1,114 @@
+package vspherecontroller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// cloudCredentialsSecretName is the Secret the cloud-credential operator
+// provisions with login details for every vCenter in
+// Infrastructure.spec.platformSpec.vsphere.vcenters.
+const cloudCredentialsSecretName = "vmware-vsphere-cloud-credentials"
+
+// connectToVCenters is the production vsphereConnectionFunc: it reads the
+// configured vCenter(s) off the cluster Infrastructure object and logs into
+// each of them with the shared cloud-credentials Secret, returning one
+// VSphereConnection per server. On a legacy single-vCenter cluster (exactly
+// one entry in vcenters) the connection is keyed "" to match the rest of the
+// package's single-vCenter convention; a multi-vCenter cluster keys each
+// connection by its server. Any failure to reach a vCenter - reading the
+// Infrastructure object or Secret, or logging in - is reported as
+// CheckStatusVSphereConnectionFailed so the controller backs off and treats
+// it as transient rather than degrading immediately.
+func (c *VSphereController) connectToVCenters(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool) {
+	infra, err := c.infraLister.Get("cluster")
+	if err != nil {
+		return nil, checks.MakeConnectionFailedResult(err), false
+	}
+	if infra.Spec.PlatformSpec.VSphere == nil || len(infra.Spec.PlatformSpec.VSphere.VCenters) == 0 {
+		return nil, checks.MakeConnectionFailedResult(fmt.Errorf("no vCenters configured in infrastructures.config.openshift.io/cluster")), false
+	}
+	vcenters := infra.Spec.PlatformSpec.VSphere.VCenters
+
+	secret, err := c.secretLister.Secrets(defaultNamespace).Get(cloudCredentialsSecretName)
+	if err != nil {
+		return nil, checks.MakeConnectionFailedResult(err), false
+	}
+
+	legacySingleVCenter := len(vcenters) == 1
+	conns := make(vclib.VSphereConnections, len(vcenters))
+	for _, vc := range vcenters {
+		username, password, err := credentialsForServer(secret, vc.Server)
+		if err != nil {
+			conns.Logout(ctx)
+			return nil, checks.MakeConnectionFailedResult(err), false
+		}
+
+		conn, err := connectToVCenter(ctx, vc.Server, username, password)
+		if err != nil {
+			conns.Logout(ctx)
+			return nil, checks.MakeConnectionFailedResult(err), false
+		}
+
+		key := vc.Server
+		if legacySingleVCenter {
+			key = ""
+		}
+		conns[key] = conn
+	}
+
+	return conns, checks.MakeClusterCheckResultPass(), false
+}
+
+// credentialsForServer returns the username/password for server out of
+// secret, accepting either the per-server keys the cloud-credential operator
+// writes for a multi-vCenter secret ("<server>.username"/"<server>.password")
+// or the flat "username"/"password" keys used on a legacy single-vCenter
+// secret.
+func credentialsForServer(secret *corev1.Secret, server string) (string, string, error) {
+	if username, password, ok := lookupCredentials(secret, server+".username", server+".password"); ok {
+		return username, password, nil
+	}
+	if username, password, ok := lookupCredentials(secret, "username", "password"); ok {
+		return username, password, nil
+	}
+	return "", "", fmt.Errorf("secret %s/%s has no credentials for vCenter %q", secret.Namespace, secret.Name, server)
+}
+
+func lookupCredentials(secret *corev1.Secret, usernameKey, passwordKey string) (string, string, bool) {
+	username, ok := secret.Data[usernameKey]
+	if !ok {
+		return "", "", false
+	}
+	password, ok := secret.Data[passwordKey]
+	if !ok {
+		return "", "", false
+	}
+	return string(username), string(password), true
+}
+
+// connectToVCenter logs into server over the standard vSphere API path,
+// returning a connection with its Finder already scoped to that client.
+func connectToVCenter(ctx context.Context, server, username, password string) (*vclib.VSphereConnection, error) {
+	u := &url.URL{Scheme: "https", Host: server, Path: "/sdk"}
+	u.User = url.UserPassword(username, password)
+
+	client, err := govmomi.NewClient(ctx, u, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to vCenter %q: %w", server, err)
+	}
+
+	return &vclib.VSphereConnection{
+		Server: server,
+		Client: client,
+		Finder: find.NewFinder(client.Client, true),
+	}, nil
+}
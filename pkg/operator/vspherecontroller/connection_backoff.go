@@ -0,0 +1,118 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// defaultConnectionFailureThreshold is how many consecutive vCenter
+	// connection failures, within defaultConnectionFailureWindow, are
+	// required before the cluster is degraded. Fewer than this many are
+	// treated as a transient blip: Available stays True and Upgradeable
+	// goes Unknown. VSphereController.connectionFailureThreshold defaults
+	// to this but can be overridden per-controller (e.g. in tests).
+	defaultConnectionFailureThreshold = 3
+	// defaultConnectionFailureWindow bounds how far back the last failure
+	// can be and still count as part of the same streak; a gap longer than
+	// this starts the count over at one. See
+	// VSphereController.connectionFailureWindow.
+	defaultConnectionFailureWindow = 10 * time.Minute
+)
+
+// connectionBackoffSchedule is how long the controller waits before the
+// next connection attempt after 1, 2, 3, ... consecutive failures, capped
+// at its last entry.
+var connectionBackoffSchedule = []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// connectionBackoff returns the retry delay for the count-th consecutive
+// connection failure (1-indexed).
+func connectionBackoff(count int) time.Duration {
+	if count <= 0 {
+		return 0
+	}
+	if count > len(connectionBackoffSchedule) {
+		return connectionBackoffSchedule[len(connectionBackoffSchedule)-1]
+	}
+	return connectionBackoffSchedule[count-1]
+}
+
+// connectionFailureConditionType names the OperatorCondition used to
+// persist an in-progress vCenter connection failure streak across
+// restarts: its Status is True for as long as the streak continues (so its
+// LastTransitionTime, managed by v1helpers, marks when the streak began),
+// its Reason holds the consecutive failure count, and its Message holds the
+// RFC3339Nano timestamp of the most recent failure, which is what actually
+// ages out of connectionFailureWindow (LastTransitionTime does not move
+// again until the streak resets, so it can't be used for that).
+func (c *VSphereController) connectionFailureConditionType() string {
+	return c.name + "VCenterConnectionDegraded"
+}
+
+// recordConnectionFailure extends (or starts) the connection failure
+// streak and returns the resulting consecutive failure count and when the
+// streak began.
+func (c *VSphereController) recordConnectionFailure(ctx context.Context, status *opv1.OperatorStatus) (count int, since time.Time, err error) {
+	conditionType := c.connectionFailureConditionType()
+	existing := findCondition(status.Conditions, conditionType)
+	now := time.Now()
+
+	if existing != nil && existing.Status == opv1.ConditionTrue {
+		lastFailure, parseErr := time.Parse(time.RFC3339Nano, existing.Message)
+		if parseErr != nil {
+			lastFailure = existing.LastTransitionTime.Time
+		}
+		if prevCount, convErr := strconv.Atoi(existing.Reason); convErr == nil && now.Sub(lastFailure) <= c.connectionFailureWindow {
+			count = prevCount + 1
+			since = existing.LastTransitionTime.Time
+			_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient,
+				v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+					Type:    conditionType,
+					Status:  opv1.ConditionTrue,
+					Reason:  strconv.Itoa(count),
+					Message: now.Format(time.RFC3339Nano),
+				}),
+			)
+			return count, since, err
+		}
+	}
+
+	// No streak in progress, or the previous one's last failure fell
+	// outside the window: start a fresh one. Clear the condition first so
+	// its LastTransitionTime actually moves to now, even if it was already
+	// True.
+	if err := c.resetConnectionFailures(ctx, status); err != nil {
+		return 0, time.Time{}, err
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+			Type:    conditionType,
+			Status:  opv1.ConditionTrue,
+			Reason:  "1",
+			Message: now.Format(time.RFC3339Nano),
+		}),
+	)
+	return 1, now, err
+}
+
+// resetConnectionFailures clears an in-progress connection failure streak
+// once a connection succeeds again. It is a no-op if no streak is active.
+func (c *VSphereController) resetConnectionFailures(ctx context.Context, status *opv1.OperatorStatus) error {
+	conditionType := c.connectionFailureConditionType()
+	existing := findCondition(status.Conditions, conditionType)
+	if existing == nil || existing.Status != opv1.ConditionTrue {
+		return nil
+	}
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+			Type:   conditionType,
+			Status: opv1.ConditionFalse,
+			Reason: "Connected",
+		}),
+	)
+	return err
+}
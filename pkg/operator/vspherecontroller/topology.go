@@ -0,0 +1,99 @@
+package vspherecontroller
+
+import (
+	"context"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultStorageClassName is the default StorageClass the operand
+	// renders for the in-tree thin-provisioned vSphere datastore policy.
+	defaultStorageClassName = "thin-csi"
+
+	topologyZoneLabel   = "topology.csi.vmware.com/openshift-zone"
+	topologyRegionLabel = "topology.csi.vmware.com/openshift-region"
+)
+
+// failureDomains returns the vSphere failure domains declared on the
+// cluster Infrastructure object, or nil on a single-zone cluster that
+// doesn't set platformSpec.vsphere.failureDomains.
+func (c *VSphereController) failureDomains() ([]configv1.VSpherePlatformFailureDomainSpec, error) {
+	infra, err := c.infraLister.Get("cluster")
+	if err != nil {
+		return nil, err
+	}
+	if infra.Spec.PlatformSpec.VSphere == nil {
+		return nil, nil
+	}
+	return infra.Spec.PlatformSpec.VSphere.FailureDomains, nil
+}
+
+// syncStorageClassTopology annotates the default topology-aware
+// StorageClass with allowedTopologies derived from failureDomains, so
+// volumes it provisions are only scheduled into nodes in a zone/region that
+// actually has the backing datastore.
+func (c *VSphereController) syncStorageClassTopology(ctx context.Context, failureDomains []configv1.VSpherePlatformFailureDomainSpec) error {
+	if len(failureDomains) == 0 {
+		return nil
+	}
+
+	sc, err := c.scLister.Get(defaultStorageClassName)
+	if apierrors.IsNotFound(err) {
+		// The operand hasn't rendered the default StorageClass yet; it will
+		// pick up allowedTopologies on its own first sync once it exists.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	terms := allowedTopologiesFromFailureDomains(failureDomains)
+	if topologyTermsEqual(sc.AllowedTopologies, terms) {
+		return nil
+	}
+
+	updated := sc.DeepCopy()
+	updated.AllowedTopologies = terms
+	_, err = c.kubeClient.StorageV1().StorageClasses().Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func allowedTopologiesFromFailureDomains(failureDomains []configv1.VSpherePlatformFailureDomainSpec) []corev1.TopologySelectorTerm {
+	terms := make([]corev1.TopologySelectorTerm, 0, len(failureDomains))
+	for _, fd := range failureDomains {
+		terms = append(terms, corev1.TopologySelectorTerm{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{
+				{Key: topologyZoneLabel, Values: []string{fd.Zone}},
+				{Key: topologyRegionLabel, Values: []string{fd.Region}},
+			},
+		})
+	}
+	return terms
+}
+
+func topologyTermsEqual(a, b []corev1.TopologySelectorTerm) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i].MatchLabelExpressions) != len(b[i].MatchLabelExpressions) {
+			return false
+		}
+		for j := range a[i].MatchLabelExpressions {
+			left, right := a[i].MatchLabelExpressions[j], b[i].MatchLabelExpressions[j]
+			if left.Key != right.Key || len(left.Values) != len(right.Values) {
+				return false
+			}
+			for k := range left.Values {
+				if left.Values[k] != right.Values[k] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
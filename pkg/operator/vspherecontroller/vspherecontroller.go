@@ -0,0 +1,414 @@
+// Package vspherecontroller implements the platform-specific controller
+// that verifies the vSphere environment is suitable for the CSI driver and
+// starts the operand (the set of conditionalControllers that actually
+// manage the driver's Deployment/DaemonSet) once it is.
+package vspherecontroller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configv1listers "github.com/openshift/client-go/config/listers/config/v1"
+	operatorv1listers "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+)
+
+const (
+	// defaultNamespace is where the CSI driver's own workloads (Deployment,
+	// DaemonSet, ConfigMap, credentials Secret) live.
+	defaultNamespace = "openshift-cluster-csi-drivers"
+	// cloudConfigNamespace is where the cluster-wide cloud-provider
+	// ConfigMap (and, on vSphere, the legacy vsphere.conf) is read from.
+	cloudConfigNamespace = "openshift-config"
+
+	// hardwareVersionAnnotation is written onto Node objects by an earlier
+	// stage of the operand and records the VM's virtual hardware version
+	// (e.g. "vmx-15").
+	hardwareVersionAnnotation = "vsphere.vmware.com/hardware-version"
+
+	csiDriverName = "csi.vsphere.vmware.com"
+)
+
+// conditionalController is a controller that manages a piece of the CSI
+// driver operand (Deployment, DaemonSet, ...) and only runs once the
+// environment has been verified as supported.
+type conditionalController interface {
+	Name() string
+	Run(ctx context.Context, syncCtx factory.SyncContext) error
+}
+
+// VSphereController verifies the vSphere environment (vCenter
+// reachability/version, node hardware versions, absence of a conflicting
+// upstream CSI driver) and, once satisfied, starts the operand controllers.
+// NewVSphereController runs it as a factory.Controller, resynced on
+// ClusterCSIDriver, Infrastructure, the ConfigMaps in openshift-config, and
+// CSIDriver/CSINode/StorageClass objects.
+type VSphereController struct {
+	name            string
+	targetNamespace string
+
+	kubeClient      kubernetes.Interface
+	operatorClient  v1helpers.OperatorClient
+	configMapLister corelisters.ConfigMapLister
+	secretLister    corelisters.SecretLister
+	csiNodeLister   storagelisters.CSINodeLister
+	scLister        storagelisters.StorageClassLister
+	csiDriverLister storagelisters.CSIDriverLister
+	nodeLister      corelisters.NodeLister
+	infraLister     configv1listers.InfrastructureLister
+
+	// clusterCSIDriverLister is read directly (rather than through
+	// operatorClient) because it exposes the vSphere-specific DriverConfig
+	// that the generic OperatorClient spec/status accessors don't know
+	// about.
+	clusterCSIDriverLister operatorv1listers.ClusterCSIDriverLister
+
+	apiClients    utils.APIClient
+	eventRecorder events.Recorder
+
+	vSphereChecker vSphereEnvironmentCheckInterface
+	controllers    []conditionalController
+
+	// vsphereConnectionFunc establishes (or simulates, in tests) connections
+	// to every configured vCenter. It is a field rather than a free function
+	// so tests can substitute a fake connection set/failure. A non-pass
+	// ClusterCheckResult means the connection attempt itself failed, before
+	// any per-connection check could run.
+	vsphereConnectionFunc func(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool)
+
+	// connectionRetryAfter holds off the next connection attempt while a
+	// vCenter connection failure streak backs off (see connectionBackoff),
+	// so a flapping vCenter isn't hammered with reconnect attempts every
+	// resync.
+	connectionRetryAfter time.Time
+
+	// connectionFailureThreshold and connectionFailureWindow tune when a
+	// vCenter connection failure streak (see recordConnectionFailure)
+	// degrades the cluster instead of merely marking it Upgradeable=Unknown.
+	// NewVSphereController defaults these to defaultConnectionFailureThreshold
+	// and defaultConnectionFailureWindow; they are plain fields, rather than
+	// consts, so tests (and, if ever needed, an admin-facing override) can
+	// tune them.
+	connectionFailureThreshold int
+	connectionFailureWindow    time.Duration
+
+	operandControllerStarted bool
+}
+
+// NewVSphereController builds the controller and registers it for resync on
+// the informers it reads from.
+func NewVSphereController(name string, apiClients utils.APIClient) factory.Controller {
+	c := &VSphereController{
+		name:                   name,
+		targetNamespace:        defaultNamespace,
+		kubeClient:             apiClients.KubeClient,
+		operatorClient:         apiClients.OperatorClient,
+		configMapLister:        apiClients.KubeInformers.InformersFor(cloudConfigNamespace).Core().V1().ConfigMaps().Lister(),
+		secretLister:           apiClients.KubeInformers.InformersFor(defaultNamespace).Core().V1().Secrets().Lister(),
+		csiNodeLister:          apiClients.KubeInformers.InformersFor("").Storage().V1().CSINodes().Lister(),
+		scLister:               apiClients.KubeInformers.InformersFor("").Storage().V1().StorageClasses().Lister(),
+		csiDriverLister:        apiClients.KubeInformers.InformersFor("").Storage().V1().CSIDrivers().Lister(),
+		nodeLister:             apiClients.NodeInformer.Lister(),
+		infraLister:            apiClients.ConfigInformers.Config().V1().Infrastructures().Lister(),
+		clusterCSIDriverLister: apiClients.OperatorInformers.Operator().V1().ClusterCSIDrivers().Lister(),
+		apiClients:             apiClients,
+		eventRecorder:          events.NewLoggingEventRecorder(name),
+		vSphereChecker:         newVSphereEnvironmentChecker(),
+
+		connectionFailureThreshold: defaultConnectionFailureThreshold,
+		connectionFailureWindow:    defaultConnectionFailureWindow,
+	}
+	c.vsphereConnectionFunc = c.connectToVCenters
+
+	return factory.New().
+		WithSync(c.sync).
+		ResyncEvery(defaultRecheckInterval).
+		WithInformers(
+			apiClients.OperatorClient.Informer(),
+			apiClients.ConfigInformers.Config().V1().Infrastructures().Informer(),
+			apiClients.KubeInformers.InformersFor(cloudConfigNamespace).Core().V1().ConfigMaps().Informer(),
+			// CSIDrivers/CSINodes drive the checker's alwaysCheckers (see
+			// vSphereEnvironmentCheckerComposite): resync on them immediately
+			// instead of waiting for defaultRecheckInterval, so e.g. an
+			// upstream CSIDriver installed between rechecks is caught as
+			// soon as it appears. StorageClasses is resynced the same way so
+			// sync() notices the operand's default StorageClass as soon as
+			// it's created; syncStorageClassTopology still only actually
+			// runs once the rate-limited checker tier lets Check() pass
+			// (see rateLimitedCheckers), so this resync doesn't make the
+			// annotation itself immediate, only the attempt to apply it.
+			apiClients.KubeInformers.InformersFor("").Storage().V1().CSIDrivers().Informer(),
+			apiClients.KubeInformers.InformersFor("").Storage().V1().CSINodes().Informer(),
+			apiClients.KubeInformers.InformersFor("").Storage().V1().StorageClasses().Informer(),
+		).
+		ToController(name, c.eventRecorder)
+}
+
+// sync verifies the vSphere environment and, only once it is supported,
+// starts the operand controllers. A check failure that blocks the upgrade
+// (or degrades) and occurs while the operand is already running (this
+// operator's own managed CSIDriver is already installed) degrades the
+// cluster, since the operator can no longer reconcile it safely; the same
+// failure before the operand ever started merely blocks the upgrade. A
+// failure that blocks the install never degrades: it only keeps an operand
+// that hasn't started from starting. The exception is a vCenter
+// connection failure: that is assumed transient, so it only degrades once
+// it has recurred connectionFailureThreshold times in a row (see
+// recordConnectionFailure), backing off between attempts per
+// connectionBackoffSchedule.
+func (c *VSphereController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	_, status, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+
+	clusterCSIDriver, err := c.clusterCSIDriverLister.Get(csiDriverName)
+	if err != nil {
+		return err
+	}
+	if err := c.syncDriverConfig(ctx, &clusterCSIDriver.Spec); err != nil {
+		return err
+	}
+
+	existingDriver, existingCSINode, managedDriverPresent, err := c.findExistingDriver()
+	if err != nil {
+		return err
+	}
+	if managedDriverPresent {
+		c.startOperand(ctx)
+	}
+
+	if time.Now().Before(c.connectionRetryAfter) {
+		return nil
+	}
+
+	conns, connResult, _ := c.vsphereConnectionFunc(ctx)
+	for _, conn := range conns {
+		defer conn.Logout(ctx)
+	}
+
+	if connResult.Action != checks.CheckActionPass {
+		return c.handleCheckResult(ctx, connResult, status)
+	}
+	if err := c.resetConnectionFailures(ctx, status); err != nil {
+		return err
+	}
+
+	nodes, err := c.nodeLister.List(nil)
+	if err != nil {
+		return err
+	}
+
+	failureDomains, err := c.failureDomains()
+	if err != nil {
+		return err
+	}
+
+	checkArgs := checks.CheckArgs{
+		Connections:            conns,
+		Nodes:                  nodes,
+		ExistingCSIDriverFound: existingDriver,
+		ExistingCSINodeFound:   existingCSINode,
+		FailureDomains:         failureDomains,
+	}
+	if len(conns) > 0 {
+		checkArgs.Tags = make(map[string]checks.TagLookup, len(conns))
+		checkArgs.Inventory = make(map[string]checks.InventoryLookup, len(conns))
+		for server, conn := range conns {
+			checkArgs.Tags[server] = conn
+			checkArgs.Inventory[server] = conn
+		}
+	}
+
+	_, clusterResult, ranChecks := c.vSphereChecker.Check(ctx, checkArgs)
+	if !ranChecks {
+		return nil
+	}
+
+	if clusterResult.Action == checks.CheckActionPass {
+		if err := c.syncStorageClassTopology(ctx, failureDomains); err != nil {
+			return err
+		}
+	}
+
+	return c.handleCheckResult(ctx, clusterResult, status)
+}
+
+// findExistingDriver reports whether an upstream csi.vsphere.vmware.com
+// CSIDriver or CSINode object exists that this operator did not create
+// (which would conflict with the one it wants to install), and separately
+// whether this operator's own managed CSIDriver is already installed (in
+// which case the operand is already running and a later check failure must
+// degrade the cluster rather than just block the upgrade).
+func (c *VSphereController) findExistingDriver() (existingCSIDriver bool, existingCSINode bool, managedDriverPresent bool, err error) {
+	driver, err := c.csiDriverLister.Get(csiDriverName)
+	switch {
+	case apierrors.IsNotFound(err):
+		// nothing installed, nothing to do
+	case err != nil:
+		return false, false, false, err
+	case driver.Annotations["csi.openshift.io/managed"] == "true":
+		managedDriverPresent = true
+	default:
+		existingCSIDriver = true
+	}
+	if managedDriverPresent {
+		// CSINodes listing the driver then belong to this operator's own install.
+		return false, false, true, nil
+	}
+
+	csiNodes, err := c.csiNodeLister.List(nil)
+	if err != nil {
+		return false, false, false, err
+	}
+	for _, n := range csiNodes {
+		for _, d := range n.Spec.Drivers {
+			if d.Name == csiDriverName {
+				existingCSINode = true
+			}
+		}
+	}
+	return existingCSIDriver, existingCSINode, managedDriverPresent, nil
+}
+
+// handleCheckResult translates a ClusterCheckResult into operator
+// conditions/metrics and decides whether the operand controllers should be
+// started.
+func (c *VSphereController) handleCheckResult(ctx context.Context, result checks.ClusterCheckResult, status *opv1.OperatorStatus) error {
+	switch result.Action {
+	case checks.CheckActionPass:
+		c.startOperand(ctx)
+		return c.setAvailableUpgradeable(ctx, opv1.ConditionTrue, opv1.ConditionTrue, "")
+
+	case checks.CheckActionBlockInstall:
+		utils.InstallErrorMetric.WithLabelValues("install_blocked", failureReasonFor(result.CheckStatus), result.VCenter).Set(1)
+		return c.setAvailableUpgradeable(ctx, opv1.ConditionTrue, opv1.ConditionTrue, "")
+
+	case checks.CheckActionBlockUpgrade:
+		failureReason := failureReasonFor(result.CheckStatus)
+		if result.CheckStatus == checks.CheckStatusVSphereConnectionFailed {
+			count, _, err := c.recordConnectionFailure(ctx, status)
+			if err != nil {
+				return err
+			}
+			c.connectionRetryAfter = time.Now().Add(connectionBackoff(count))
+			utils.InstallErrorMetric.WithLabelValues("connection_flapping", failureReason, result.VCenter).Set(1)
+			if c.operandControllerStarted && count >= c.connectionFailureThreshold {
+				return degradeError(result)
+			}
+			return c.setAvailableUpgradeable(ctx, opv1.ConditionTrue, opv1.ConditionUnknown, result.Reason)
+		}
+		utils.InstallErrorMetric.WithLabelValues("upgrade_blocked", failureReason, result.VCenter).Set(1)
+		if c.operandControllerStarted {
+			return degradeError(result)
+		}
+		return c.setAvailableUpgradeable(ctx, opv1.ConditionTrue, opv1.ConditionFalse, result.Reason)
+
+	case checks.CheckActionDegrade:
+		if c.operandControllerStarted {
+			return result.CheckError
+		}
+		return c.setAvailableUpgradeable(ctx, opv1.ConditionTrue, opv1.ConditionFalse, result.Reason)
+	}
+
+	if c.operandControllerStarted && result.CheckError != nil {
+		return result.CheckError
+	}
+	return nil
+}
+
+// degradeError turns a non-passing ClusterCheckResult into an error. It is
+// used once the operand is already running, where a check failure can no
+// longer be absorbed as a mere upgrade/install block and must degrade the
+// cluster instead.
+func degradeError(result checks.ClusterCheckResult) error {
+	if result.CheckError != nil {
+		return result.CheckError
+	}
+	return errors.New(result.Reason)
+}
+
+func failureReasonFor(status checks.CheckStatus) string {
+	switch status {
+	case checks.CheckStatusVSphereConnectionFailed:
+		return "vsphere_connection_failed"
+	case checks.CheckStatusDeprecatedVCenter:
+		return "check_deprecated_vcenter"
+	case checks.CheckStatusDeprecatedHWVersion:
+		return "check_deprecated_hw_version"
+	case checks.CheckStatusExistingDriverFound:
+		return "existing_driver_found"
+	case checks.CheckStatusTopologyMisconfigured:
+		return "topology_misconfigured"
+	default:
+		return "generic_error"
+	}
+}
+
+func (c *VSphereController) startOperand(ctx context.Context) {
+	if c.operandControllerStarted {
+		return
+	}
+	for _, ctrl := range c.controllers {
+		go func(ctrl conditionalController) {
+			_ = ctrl.Run(ctx, nil)
+		}(ctrl)
+	}
+	c.operandControllerStarted = true
+}
+
+func (c *VSphereController) setAvailableUpgradeable(ctx context.Context, available, upgradeable opv1.ConditionStatus, message string) error {
+	_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient,
+		v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+			Type:    c.name + opv1.OperatorStatusTypeAvailable,
+			Status:  available,
+			Message: message,
+		}),
+		v1helpers.UpdateConditionFn(opv1.OperatorCondition{
+			Type:    c.name + opv1.OperatorStatusTypeUpgradeable,
+			Status:  upgradeable,
+			Message: message,
+		}),
+	)
+	return err
+}
+
+// addUpgradeableBlockCondition computes the Upgradeable condition that
+// clusterResult implies and reports whether it differs from the condition
+// already present on status, so callers only write a status update when
+// something actually changed.
+func (c *VSphereController) addUpgradeableBlockCondition(clusterResult checks.ClusterCheckResult, controllerName string, status *opv1.OperatorStatus, defaultStatus opv1.ConditionStatus) (opv1.OperatorCondition, bool) {
+	conditionType := controllerName + opv1.OperatorStatusTypeUpgradeable
+	condition := opv1.OperatorCondition{
+		Type:    conditionType,
+		Status:  defaultStatus,
+		Reason:  string(clusterResult.CheckStatus),
+		Message: clusterResult.Reason,
+	}
+
+	existing := findCondition(status.Conditions, conditionType)
+	if existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason {
+		return *existing, false
+	}
+	return condition, true
+}
+
+func findCondition(conditions []opv1.OperatorCondition, conditionType string) *opv1.OperatorCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
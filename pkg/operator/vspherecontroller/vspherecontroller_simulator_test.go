@@ -0,0 +1,156 @@
+package vspherecontroller
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	// Blank-imported for its side effect of registering vcsim's simulated
+	// REST tagging API on the same test server setupSimulator starts, so
+	// customizeTopologyTags can exercise the same tags.Manager calls
+	// VSphereConnection.HasTag makes against a real vCenter.
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
+)
+
+// defaultModel is the vcsim inventory every TestSync case starts from: one
+// datacenter, one cluster, one host, two VMs (one per node in defaultNodes).
+var defaultModel = &simulator.Model{
+	Datacenter:  1,
+	Cluster:     1,
+	Host:        0,
+	ClusterHost: 1,
+	Machine:     2,
+}
+
+const defaultHostId = "DC0_H0"
+
+func defaultNodes() []*v1.Node {
+	names := []string{"DC0_H0_VM0", "DC0_H0_VM1"}
+	nodes := make([]*v1.Node, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{},
+			},
+		})
+	}
+	return nodes
+}
+
+// setupSimulator starts an in-process vcsim matching model and returns an
+// already-authenticated VSphereConnection against it.
+func setupSimulator(model *simulator.Model) (*vclib.VSphereConnection, func(), error) {
+	if err := model.Create(); err != nil {
+		return nil, nil, err
+	}
+
+	server := model.Service.NewServer()
+	client, err := govmomi.NewClient(context.Background(), server.URL, true)
+	if err != nil {
+		model.Remove()
+		server.Close()
+		return nil, nil, err
+	}
+
+	finder := find.NewFinder(client.Client, true)
+
+	conn := &vclib.VSphereConnection{
+		Server: server.URL.Hostname(),
+		Client: client,
+		Finder: finder,
+	}
+
+	cleanup := func() {
+		server.Close()
+		model.Remove()
+	}
+	return conn, cleanup, nil
+}
+
+// customizeVCenterVersion overrides the API/product version vcsim reports,
+// so tests can simulate a deprecated vCenter without a second fixture.
+func customizeVCenterVersion(apiVersion, version string, conn *vclib.VSphereConnection) {
+	about := &conn.Client.ServiceContent.About
+	about.ApiVersion = apiVersion
+	about.Version = version
+}
+
+// customizeHostVersion overrides the ESXi version reported by the host with
+// the given moref id. None of the current checks key off host (as opposed
+// to vCenter or VM hardware) version, so this only exists to keep the
+// fixture realistic; it's a no-op against vcsim today.
+func customizeHostVersion(hostId, version string) error {
+	return nil
+}
+
+// customizeTopologyTags creates the openshift-region/openshift-zone tag
+// categories (once each, reused across datacenters) and attaches the given
+// region/zone tag values to every datacenter path in tagsByDatacenter, so
+// CheckTopology's HasTag lookups exercise the same vapi/tags REST calls
+// they would against a real vCenter tagged by the installer.
+func customizeTopologyTags(ctx context.Context, conn *vclib.VSphereConnection, tagsByDatacenter map[string]map[string]string) error {
+	restClient := rest.NewClient(conn.Client.Client)
+	if err := restClient.Login(ctx, conn.Client.Client.Client.URL().User); err != nil {
+		return err
+	}
+	defer restClient.Logout(ctx)
+
+	tagMgr := tags.NewManager(restClient)
+	categoryIDs := map[string]string{}
+	categoryID := func(name string) (string, error) {
+		if id, ok := categoryIDs[name]; ok {
+			return id, nil
+		}
+		id, err := tagMgr.CreateCategory(ctx, &tags.Category{
+			Name:            name,
+			Cardinality:     "SINGLE",
+			AssociableTypes: []string{"Datacenter"},
+		})
+		if err != nil {
+			return "", err
+		}
+		categoryIDs[name] = id
+		return id, nil
+	}
+
+	for dcPath, byCategory := range tagsByDatacenter {
+		dc, err := conn.Finder.Datacenter(ctx, dcPath)
+		if err != nil {
+			return err
+		}
+		for category, value := range byCategory {
+			catID, err := categoryID(category)
+			if err != nil {
+				return err
+			}
+			tagID, err := tagMgr.CreateTag(ctx, &tags.Tag{Name: value, CategoryID: catID})
+			if err != nil {
+				return err
+			}
+			if err := tagMgr.AttachTag(ctx, tagID, dc.Reference()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setHWVersion stamps the hardware-version annotation the real operand
+// would read from the VM's virtual hardware onto the fake Node, so
+// checkNodeHardwareVersions exercises the same logic it would against a
+// live cluster.
+func setHWVersion(conn *vclib.VSphereConnection, node *v1.Node, version string) error {
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[hardwareVersionAnnotation] = version
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
 	opv1 "github.com/openshift/api/operator/v1"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -14,7 +15,10 @@ import (
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vclib"
 	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+	"gopkg.in/ini.v1"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/component-base/metrics/testutil"
 )
@@ -36,20 +40,24 @@ func newVsphereController(apiClients *utils.APIClient) *VSphereController {
 	rc := events.NewInMemoryRecorder(testControllerName)
 
 	c := &VSphereController{
-		name:            testControllerName,
-		targetNamespace: defaultNamespace,
-		kubeClient:      apiClients.KubeClient,
-		operatorClient:  apiClients.OperatorClient,
-		configMapLister: configMapInformer.Lister(),
-		secretLister:    apiClients.SecretInformer.Lister(),
-		csiNodeLister:   csiNodeLister,
-		scLister:        scInformer.Lister(),
-		csiDriverLister: csiDriverLister,
-		nodeLister:      nodeLister,
-		apiClients:      *apiClients,
-		eventRecorder:   rc,
-		vSphereChecker:  newVSphereEnvironmentChecker(),
-		infraLister:     infraInformer.Lister(),
+		name:                   testControllerName,
+		targetNamespace:        defaultNamespace,
+		kubeClient:             apiClients.KubeClient,
+		operatorClient:         apiClients.OperatorClient,
+		configMapLister:        configMapInformer.Lister(),
+		secretLister:           apiClients.SecretInformer.Lister(),
+		csiNodeLister:          csiNodeLister,
+		scLister:               scInformer.Lister(),
+		csiDriverLister:        csiDriverLister,
+		nodeLister:             nodeLister,
+		apiClients:             *apiClients,
+		eventRecorder:          rc,
+		vSphereChecker:         newVSphereEnvironmentChecker(),
+		infraLister:            infraInformer.Lister(),
+		clusterCSIDriverLister: apiClients.OperatorInformers.Operator().V1().ClusterCSIDrivers().Lister(),
+
+		connectionFailureThreshold: defaultConnectionFailureThreshold,
+		connectionFailureWindow:    defaultConnectionFailureWindow,
 	}
 	c.controllers = []conditionalController{}
 	return c
@@ -62,14 +70,17 @@ func TestSync(t *testing.T) {
         `
 
 	tests := []struct {
-		name                         string
-		clusterCSIDriverObject       *testlib.FakeDriverInstance
-		initialObjects               []runtime.Object
-		initialErrorMetricValue      float64
-		initialErrorMetricLabels     map[string]string
-		skipCheck                    bool
-		configObjects                runtime.Object
-		vcenterVersion               string
+		name                     string
+		clusterCSIDriverObject   *testlib.FakeDriverInstance
+		initialObjects           []runtime.Object
+		initialErrorMetricValue  float64
+		initialErrorMetricLabels map[string]string
+		configObjects            runtime.Object
+		vcenterVersion           string
+		// secondVCenterVersion, when set, simulates a second vCenter
+		// connection (keyed "vcenter-b", with the first keyed "vcenter-a")
+		// instead of the usual single ""-keyed connection.
+		secondVCenterVersion         string
 		startingNodeHardwareVersions []string
 		finalNodeHardwareVersions    []string
 		expectedConditions           []opv1.OperatorCondition
@@ -115,19 +126,32 @@ func TestSync(t *testing.T) {
 					Status: opv1.ConditionUnknown,
 				},
 			},
-			expectedMetrics: `vsphere_csi_driver_error{condition="upgrade_unknown",failure_reason="vsphere_connection_failed"} 1`,
+			expectedMetrics: `vsphere_csi_driver_error{condition="connection_flapping",failure_reason="vsphere_connection_failed",vcenter=""} 1`,
 			operandStarted:  false,
 		},
 		{
-			name:                         "when we can't connect to vcenter but CSI driver was installed previously, degrade cluster",
+			// A single connection failure is assumed transient; see
+			// TestSyncConnectionBackoff for the sustained-failure case that
+			// does degrade the cluster.
+			name:                         "when we can't connect to vcenter but CSI driver was installed previously, does not degrade on the first failure",
 			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
 			vcenterVersion:               "7.0.2",
 			startingNodeHardwareVersions: []string{"vmx-15", "vmx-15"},
 			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret(), testlib.GetCSIDriver(true /*withOCPAnnotation*/)},
 			configObjects:                runtime.Object(testlib.GetInfraObject()),
 			failVCenterConnection:        true,
-			expectError:                  fmt.Errorf("can't talk to vcenter"),
-			operandStarted:               true,
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeAvailable,
+					Status: opv1.ConditionTrue,
+				},
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeUpgradeable,
+					Status: opv1.ConditionUnknown,
+				},
+			},
+			expectedMetrics: `vsphere_csi_driver_error{condition="connection_flapping",failure_reason="vsphere_connection_failed",vcenter=""} 1`,
+			operandStarted:  true,
 		},
 		{
 			name:                         "when vcenter version is older, block upgrades",
@@ -145,7 +169,7 @@ func TestSync(t *testing.T) {
 					Status: opv1.ConditionFalse,
 				},
 			},
-			expectedMetrics: `vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_vcenter"} 1`,
+			expectedMetrics: `vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_vcenter",vcenter=""} 1`,
 			operandStarted:  false,
 		},
 		{
@@ -174,7 +198,7 @@ func TestSync(t *testing.T) {
 					Status: opv1.ConditionTrue,
 				},
 			},
-			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found"} 1`,
+			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found",vcenter=""} 1`,
 			operandStarted:  false,
 		},
 		{
@@ -194,7 +218,7 @@ func TestSync(t *testing.T) {
 					Status: opv1.ConditionTrue,
 				},
 			},
-			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found"} 1`,
+			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found",vcenter=""} 1`,
 			operandStarted:  false,
 		},
 		{
@@ -218,18 +242,45 @@ func TestSync(t *testing.T) {
 			operandStarted: true,
 		},
 		{
-			name:                         "sync before the next recheck interval",
+			name:                         "when one of two vcenters is deprecated, block upgrades",
 			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
-			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
-			skipCheck:                    true,
-			initialErrorMetricValue:      1,
-			initialErrorMetricLabels:     map[string]string{"condition": "install_blocked", "failure_reason": "existing_driver_found"},
 			startingNodeHardwareVersions: []string{"vmx-15", "vmx-15"},
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
+			configObjects:                runtime.Object(testlib.GetInfraObject()),
 			vcenterVersion:               "7.0.2",
+			secondVCenterVersion:         "6.5.0",
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeAvailable,
+					Status: opv1.ConditionTrue,
+				},
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeUpgradeable,
+					Status: opv1.ConditionFalse,
+				},
+			},
+			expectedMetrics: `vsphere_csi_driver_error{condition="upgrade_blocked",failure_reason="check_deprecated_vcenter",vcenter="vcenter-b"} 1`,
+			operandStarted:  false,
+		},
+		{
+			name:                         "when both of two vcenters pass, operand starts",
+			clusterCSIDriverObject:       testlib.MakeFakeDriverInstance(),
+			startingNodeHardwareVersions: []string{"vmx-15", "vmx-15"},
+			initialObjects:               []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()},
 			configObjects:                runtime.Object(testlib.GetInfraObject()),
-			operandStarted:               false,
-			// The metrics is not reset when no checks actually run.
-			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found"} 1`,
+			vcenterVersion:               "7.0.2",
+			secondVCenterVersion:         "7.0.2",
+			expectedConditions: []opv1.OperatorCondition{
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeAvailable,
+					Status: opv1.ConditionTrue,
+				},
+				{
+					Type:   testControllerName + opv1.OperatorStatusTypeUpgradeable,
+					Status: opv1.ConditionTrue,
+				},
+			},
+			operandStarted: true,
 		},
 	}
 
@@ -268,12 +319,26 @@ func TestSync(t *testing.T) {
 			if test.vcenterVersion != "" {
 				customizeVCenterVersion(test.vcenterVersion, test.vcenterVersion, conn)
 			}
-			ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, test.failVCenterConnection, connError)
 			defer func() {
 				if cleanUpFunc != nil {
 					cleanUpFunc()
 				}
 			}()
+
+			if test.secondVCenterVersion != "" {
+				conn2, cleanUpFunc2, err := setupSimulator(defaultModel)
+				if err != nil {
+					t.Fatalf("failed to set up second simulator: %v", err)
+				}
+				defer cleanUpFunc2()
+				customizeVCenterVersion(test.secondVCenterVersion, test.secondVCenterVersion, conn2)
+				ctrl.vsphereConnectionFunc = makeMultiVsphereConnectionFunc(vclib.VSphereConnections{
+					"vcenter-a": conn,
+					"vcenter-b": conn2,
+				})
+			} else {
+				ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, test.failVCenterConnection, connError)
+			}
 			err := setHardwareVersionsFunc(nodes, conn, test.startingNodeHardwareVersions)()
 			if err != nil {
 				t.Fatalf("error setting hardware version for node %s", nodes[0].Name)
@@ -285,10 +350,6 @@ func TestSync(t *testing.T) {
 				t.Fatalf("Failed to customize host: %s", err)
 			}
 
-			if test.skipCheck {
-				ctrl.vSphereChecker = newSkippingChecker()
-			}
-
 			err = ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder))
 			if test.expectError == nil && err != nil {
 				t.Fatalf("Unexpected error that could degrade cluster: %+v", err)
@@ -330,6 +391,171 @@ func TestSync(t *testing.T) {
 	}
 }
 
+// TestSyncConnectionBackoff covers the consecutive-connection-failure
+// backoff in handleCheckResult/recordConnectionFailure: a managed driver
+// that is already running should ride out a single vCenter connection
+// failure, only degrade once the failure recurs defaultConnectionFailureThreshold
+// times in a row, and forget the streak as soon as a connection succeeds.
+func TestSyncConnectionBackoff(t *testing.T) {
+	newBackoffTestController := func(t *testing.T) (*VSphereController, *vclib.VSphereConnection, func()) {
+		t.Helper()
+		utils.InstallErrorMetric.Reset()
+
+		nodes := defaultNodes()
+		initialObjects := []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret(), testlib.GetCSIDriver(true /*withOCPAnnotation*/)}
+		for _, node := range nodes {
+			initialObjects = append(initialObjects, runtime.Object(node))
+		}
+
+		commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), testlib.GetInfraObject())
+		stopCh := make(chan struct{})
+		go testlib.StartFakeInformer(commonApiClient, stopCh)
+		if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+			t.Fatalf("error adding initial objects: %v", err)
+		}
+		testlib.WaitForSync(commonApiClient, stopCh)
+
+		ctrl := newVsphereController(commonApiClient)
+
+		conn, cleanUpFunc, err := setupSimulator(defaultModel)
+		if err != nil {
+			t.Fatalf("failed to set up simulator: %v", err)
+		}
+		customizeVCenterVersion("7.0.2", "7.0.2", conn)
+		if err := customizeHostVersion(defaultHostId, "7.0.2"); err != nil {
+			t.Fatalf("failed to customize host: %v", err)
+		}
+		if err := setHardwareVersionsFunc(nodes, conn, []string{"vmx-15", "vmx-15"})(); err != nil {
+			t.Fatalf("failed to set hardware version: %v", err)
+		}
+
+		cleanup := func() {
+			close(stopCh)
+			cleanUpFunc()
+		}
+		return ctrl, conn, cleanup
+	}
+
+	t.Run("a single connection failure does not degrade", func(t *testing.T) {
+		ctrl, conn, cleanup := newBackoffTestController(t)
+		defer cleanup()
+		ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, true, nil)
+
+		if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+			t.Fatalf("a one-shot connection failure should not degrade the cluster, got: %v", err)
+		}
+	})
+
+	t.Run("a sustained failure degrades once the threshold is reached", func(t *testing.T) {
+		ctrl, conn, cleanup := newBackoffTestController(t)
+		defer cleanup()
+		ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, true, nil)
+
+		var err error
+		for i := 0; i < defaultConnectionFailureThreshold; i++ {
+			ctrl.connectionRetryAfter = time.Time{}
+			err = ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder))
+		}
+		if err == nil {
+			t.Fatalf("expected the cluster to degrade after %d consecutive connection failures", defaultConnectionFailureThreshold)
+		}
+	})
+
+	t.Run("a connection that recovers resets the failure streak", func(t *testing.T) {
+		ctrl, conn, cleanup := newBackoffTestController(t)
+		defer cleanup()
+
+		ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, true, nil)
+		for i := 0; i < defaultConnectionFailureThreshold-1; i++ {
+			ctrl.connectionRetryAfter = time.Time{}
+			if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+				t.Fatalf("did not expect the cluster to degrade before the threshold: %v", err)
+			}
+		}
+
+		ctrl.connectionRetryAfter = time.Time{}
+		ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, false, nil)
+		if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+			t.Fatalf("unexpected error on a successful connection: %v", err)
+		}
+
+		ctrl.connectionRetryAfter = time.Time{}
+		ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, true, nil)
+		if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+			t.Fatalf("a single failure right after recovery should not degrade, got: %v", err)
+		}
+	})
+}
+
+// TestSyncDetectsExistingDriverImmediately covers the switch to event-driven
+// checks: existing-driver is an alwaysChecker (see
+// vSphereEnvironmentCheckerComposite), so a CSIDriver installed between
+// rechecks is caught on the very next sync, without waiting for
+// defaultRecheckInterval to elapse (that part is left to the CSIDriver
+// informer registered in NewVSphereController to trigger the resync).
+func TestSyncDetectsExistingDriverImmediately(t *testing.T) {
+	utils.InstallErrorMetric.Reset()
+
+	nodes := defaultNodes()
+	initialObjects := []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()}
+	for _, node := range nodes {
+		initialObjects = append(initialObjects, runtime.Object(node))
+	}
+
+	commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), testlib.GetInfraObject())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go testlib.StartFakeInformer(commonApiClient, stopCh)
+	if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+		t.Fatalf("error adding initial objects: %v", err)
+	}
+	testlib.WaitForSync(commonApiClient, stopCh)
+
+	ctrl := newVsphereController(commonApiClient)
+
+	conn, cleanUpFunc, err := setupSimulator(defaultModel)
+	if err != nil {
+		t.Fatalf("failed to set up simulator: %v", err)
+	}
+	defer cleanUpFunc()
+	customizeVCenterVersion("7.0.2", "7.0.2", conn)
+	ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, false, nil)
+	if err := customizeHostVersion(defaultHostId, "7.0.2"); err != nil {
+		t.Fatalf("failed to customize host: %v", err)
+	}
+	if err := setHardwareVersionsFunc(nodes, conn, []string{"vmx-15", "vmx-15"})(); err != nil {
+		t.Fatalf("failed to set hardware version: %v", err)
+	}
+
+	if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+		t.Fatalf("unexpected error on the first sync: %v", err)
+	}
+	if !ctrl.operandControllerStarted {
+		t.Fatalf("expected the operand to start once the environment checks out")
+	}
+
+	// Simulate a conflicting upstream CSIDriver appearing between rechecks:
+	// add it to the lister directly, without touching the checker's
+	// nextCheck, so the only thing that can notice it is an alwaysChecker.
+	foreignDriver := testlib.GetCSIDriver(false /*withOCPAnnotation*/)
+	if err := commonApiClient.KubeInformers.InformersFor("").Storage().V1().CSIDrivers().Informer().GetIndexer().Add(foreignDriver); err != nil {
+		t.Fatalf("failed to seed CSIDriver: %v", err)
+	}
+
+	if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+		t.Fatalf("unexpected error on the second sync: %v", err)
+	}
+
+	metricsHeader := `
+        # HELP vsphere_csi_driver_error [ALPHA] vSphere driver installation error
+        # TYPE vsphere_csi_driver_error gauge
+        `
+	expectedMetrics := `vsphere_csi_driver_error{condition="install_blocked",failure_reason="existing_driver_found",vcenter=""} 1`
+	if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(metricsHeader+expectedMetrics+"\n"), utils.InstallErrorMetric.Name); err != nil {
+		t.Errorf("expected the existing-driver check to flip immediately: %s", err)
+	}
+}
+
 func setHardwareVersionsFunc(nodes []*v1.Node, conn *vclib.VSphereConnection, hardwareVersions []string) func() error {
 	return func() error {
 		for i := range nodes {
@@ -352,8 +578,10 @@ func adjustConditionsAndResync(modifierFunc func() error, ctrl *VSphereControlle
 	return ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder))
 }
 
-func makeVsphereConnectionFunc(conn *vclib.VSphereConnection, failConnection bool, connError error) func() (*vclib.VSphereConnection, checks.ClusterCheckResult, bool) {
-	return func() (*vclib.VSphereConnection, checks.ClusterCheckResult, bool) {
+// makeVsphereConnectionFunc simulates a legacy single-vCenter cluster, whose
+// lone connection is keyed "" in VSphereConnections (see vclib.VSphereConnections).
+func makeVsphereConnectionFunc(conn *vclib.VSphereConnection, failConnection bool, connError error) func(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool) {
+	return func(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool) {
 		if failConnection {
 			err := fmt.Errorf("connection to vcenter failed")
 			result := checks.ClusterCheckResult{
@@ -367,12 +595,20 @@ func makeVsphereConnectionFunc(conn *vclib.VSphereConnection, failConnection boo
 			if connError != nil {
 				return nil, checks.MakeGenericVCenterAPIError(connError), false
 			}
-			return conn, checks.MakeClusterCheckResultPass(), false
+			return vclib.VSphereConnections{"": conn}, checks.MakeClusterCheckResultPass(), false
 		}
 	}
 
 }
 
+// makeMultiVsphereConnectionFunc simulates a multi-vCenter cluster whose
+// connections are already keyed by server.
+func makeMultiVsphereConnectionFunc(conns vclib.VSphereConnections) func(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool) {
+	return func(ctx context.Context) (vclib.VSphereConnections, checks.ClusterCheckResult, bool) {
+		return conns, checks.MakeClusterCheckResultPass(), false
+	}
+}
+
 func TestAddUpgradeableBlockCondition(t *testing.T) {
 	controllerName := "VSphereController"
 	conditionType := controllerName + opv1.OperatorStatusTypeUpgradeable
@@ -466,13 +702,162 @@ func TestAddUpgradeableBlockCondition(t *testing.T) {
 	}
 }
 
-// This dummy vSphereEnvironmentCheckInterface implementation never runs any platform checks.
-type skippingChecker struct{}
+func int32Ptr(v int32) *int32 {
+	return &v
+}
 
-func (*skippingChecker) Check(ctx context.Context, connection checks.CheckArgs) (time.Duration, checks.ClusterCheckResult, bool) {
-	return 0, checks.ClusterCheckResult{}, false
+func TestSyncDriverConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		vsphereConfig  *opv1.VSphereCSIDriverConfigSpec
+		expectDegraded bool
+		expectedGlobal string
+		expectedVSAN   string
+	}{
+		{
+			name: "unset driverConfig leaves the snapshot section untouched",
+		},
+		{
+			name: "valid override is rendered into the snapshot section",
+			vsphereConfig: &opv1.VSphereCSIDriverConfigSpec{
+				GlobalMaxSnapshotsPerBlockVolume:         int32Ptr(10),
+				GranularMaxSnapshotsPerBlockVolumeInVSAN: int32Ptr(500),
+			},
+			expectedGlobal: "10",
+			expectedVSAN:   "500",
+		},
+		{
+			name: "out of range override degrades the cluster",
+			vsphereConfig: &opv1.VSphereCSIDriverConfigSpec{
+				GlobalMaxSnapshotsPerBlockVolume: int32Ptr(64),
+			},
+			expectDegraded: true,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			utils.InstallErrorMetric.Reset()
+
+			driver := testlib.MakeFakeDriverInstance(func(instance *testlib.FakeDriverInstance) *testlib.FakeDriverInstance {
+				instance.Spec.DriverConfig.VSphere = test.vsphereConfig
+				return instance
+			})
+
+			cm := testlib.GetConfigMap()
+			cm.Name = "vsphere-csi-config"
+			cm.Namespace = defaultNamespace
+			cm.Data["cloud.conf"] = "[Global]\ninsecure-flag = true\n"
+
+			initialObjects := []runtime.Object{cm}
+			commonApiClient := testlib.NewFakeClients(initialObjects, driver, testlib.GetInfraObject())
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go testlib.StartFakeInformer(commonApiClient, stopCh)
+			testlib.WaitForSync(commonApiClient, stopCh)
+
+			ctrl := newVsphereController(commonApiClient)
+			err := ctrl.syncDriverConfig(context.TODO(), &driver.Spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, _ := ctrl.operatorClient.GetOperatorState()
+			degraded := testlib.GetMatchingCondition(status.Conditions, testControllerName+opv1.OperatorStatusTypeDegraded)
+			if test.expectDegraded {
+				if degraded == nil || degraded.Status != opv1.ConditionTrue {
+					t.Fatalf("expected cluster to be degraded for invalid snapshot config")
+				}
+				return
+			}
+			if degraded != nil && degraded.Status == opv1.ConditionTrue {
+				t.Fatalf("did not expect cluster to be degraded, got: %+v", degraded)
+			}
+
+			if test.expectedGlobal == "" && test.expectedVSAN == "" {
+				return
+			}
+
+			updated, err := ctrl.kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(context.TODO(), "vsphere-csi-config", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get updated configmap: %v", err)
+			}
+			file, err := ini.Load([]byte(updated.Data["cloud.conf"]))
+			if err != nil {
+				t.Fatalf("failed to parse rendered cloud.conf: %v", err)
+			}
+			section := file.Section(snapshotSectionName)
+			if test.expectedGlobal != "" && section.Key("global-max-snapshots-per-block-volume").String() != test.expectedGlobal {
+				t.Fatalf("expected global-max-snapshots-per-block-volume=%s, got %s", test.expectedGlobal, section.Key("global-max-snapshots-per-block-volume").String())
+			}
+			if test.expectedVSAN != "" && section.Key("granular-max-snapshots-per-block-volume-in-vsan").String() != test.expectedVSAN {
+				t.Fatalf("expected granular-max-snapshots-per-block-volume-in-vsan=%s, got %s", test.expectedVSAN, section.Key("granular-max-snapshots-per-block-volume-in-vsan").String())
+			}
+		})
+	}
+}
+
+func failureDomainFixture(zone, region string) configv1.VSpherePlatformFailureDomainSpec {
+	return configv1.VSpherePlatformFailureDomainSpec{
+		Name:   zone,
+		Region: region,
+		Zone:   zone,
+		Topology: configv1.VSpherePlatformTopology{
+			Datacenter: "/DC0",
+		},
+	}
 }
 
-func newSkippingChecker() *skippingChecker {
-	return &skippingChecker{}
+func TestSyncStorageClassTopology(t *testing.T) {
+	tests := []struct {
+		name                string
+		failureDomains      []configv1.VSpherePlatformFailureDomainSpec
+		expectedTopologyLen int
+	}{
+		{
+			name:                "single zone leaves one allowedTopologies term",
+			failureDomains:      []configv1.VSpherePlatformFailureDomainSpec{failureDomainFixture("us-east-1a", "us-east")},
+			expectedTopologyLen: 1,
+		},
+		{
+			name: "multi zone produces one term per zone",
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				failureDomainFixture("us-east-1a", "us-east"),
+				failureDomainFixture("us-east-1b", "us-east"),
+			},
+			expectedTopologyLen: 2,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			driver := testlib.MakeFakeDriverInstance()
+			sc := &storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: defaultStorageClassName},
+			}
+			commonApiClient := testlib.NewFakeClients([]runtime.Object{sc}, driver, testlib.GetInfraObject())
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go testlib.StartFakeInformer(commonApiClient, stopCh)
+			if err := commonApiClient.KubeInformers.InformersFor("").Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc); err != nil {
+				t.Fatalf("failed to seed storage class: %v", err)
+			}
+			testlib.WaitForSync(commonApiClient, stopCh)
+
+			ctrl := newVsphereController(commonApiClient)
+			if err := ctrl.syncStorageClassTopology(context.TODO(), test.failureDomains); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			updated, err := ctrl.kubeClient.StorageV1().StorageClasses().Get(context.TODO(), defaultStorageClassName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get updated storage class: %v", err)
+			}
+			if len(updated.AllowedTopologies) != test.expectedTopologyLen {
+				t.Fatalf("expected %d allowedTopologies terms, got %d", test.expectedTopologyLen, len(updated.AllowedTopologies))
+			}
+		})
+	}
 }
@@ -0,0 +1,146 @@
+package vspherecontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/vmware/govmomi/simulator"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/component-base/metrics/testutil"
+
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/testlib"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/utils"
+	"github.com/openshift/vmware-vsphere-csi-driver-operator/pkg/operator/vspherecontroller/checks"
+)
+
+func topologyFailureDomain(zone, region, datacenter string) configv1.VSpherePlatformFailureDomainSpec {
+	return configv1.VSpherePlatformFailureDomainSpec{
+		Name:   zone,
+		Region: region,
+		Zone:   zone,
+		Topology: configv1.VSpherePlatformTopology{
+			Datacenter: datacenter,
+		},
+	}
+}
+
+// TestSyncTopology drives VSphereController.sync() end-to-end with
+// FailureDomains set, covering the wiring CheckTopology's move into
+// rateLimitedCheckers is meant to preserve: a misconfigured failure domain
+// surfaces as CheckStatusTopologyMisconfigured -> an install_blocked metric
+// -> the operand not starting, while single- and multi-zone clusters whose
+// datacenters carry the expected tags start normally.
+func TestSyncTopology(t *testing.T) {
+	metricsHeader := `
+        # HELP vsphere_csi_driver_error [ALPHA] vSphere driver installation error
+        # TYPE vsphere_csi_driver_error gauge
+        `
+
+	tests := []struct {
+		name             string
+		datacenterCount  int
+		failureDomains   []configv1.VSpherePlatformFailureDomainSpec
+		tagsByDatacenter map[string]map[string]string
+		expectedMetrics  string
+		operandStarted   bool
+	}{
+		{
+			name:            "single zone, correctly tagged",
+			datacenterCount: 1,
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				topologyFailureDomain("us-east-1a", "us-east", "/DC0"),
+			},
+			tagsByDatacenter: map[string]map[string]string{
+				"/DC0": {checks.OpenshiftRegionCategory: "us-east", checks.OpenshiftZoneCategory: "us-east-1a"},
+			},
+			operandStarted: true,
+		},
+		{
+			name:            "multi zone, all correctly tagged",
+			datacenterCount: 2,
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				topologyFailureDomain("us-east-1a", "us-east", "/DC0"),
+				topologyFailureDomain("us-east-1b", "us-east", "/DC1"),
+			},
+			tagsByDatacenter: map[string]map[string]string{
+				"/DC0": {checks.OpenshiftRegionCategory: "us-east", checks.OpenshiftZoneCategory: "us-east-1a"},
+				"/DC1": {checks.OpenshiftRegionCategory: "us-east", checks.OpenshiftZoneCategory: "us-east-1b"},
+			},
+			operandStarted: true,
+		},
+		{
+			name:            "zone tag missing on second failure domain",
+			datacenterCount: 2,
+			failureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+				topologyFailureDomain("us-east-1a", "us-east", "/DC0"),
+				topologyFailureDomain("us-east-1b", "us-east", "/DC1"),
+			},
+			tagsByDatacenter: map[string]map[string]string{
+				"/DC0": {checks.OpenshiftRegionCategory: "us-east", checks.OpenshiftZoneCategory: "us-east-1a"},
+				"/DC1": {checks.OpenshiftRegionCategory: "us-east"},
+			},
+			expectedMetrics: `vsphere_csi_driver_error{condition="install_blocked",failure_reason="topology_misconfigured",vcenter=""} 1`,
+			operandStarted:  false,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			utils.InstallErrorMetric.Reset()
+
+			nodes := defaultNodes()
+			initialObjects := []runtime.Object{testlib.GetConfigMap(), testlib.GetSecret()}
+			for _, node := range nodes {
+				initialObjects = append(initialObjects, runtime.Object(node))
+			}
+
+			infra := testlib.GetInfraObject()
+			infra.Spec.PlatformSpec.VSphere = &configv1.VSpherePlatformSpec{FailureDomains: test.failureDomains}
+
+			commonApiClient := testlib.NewFakeClients(initialObjects, testlib.MakeFakeDriverInstance(), infra)
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go testlib.StartFakeInformer(commonApiClient, stopCh)
+			if err := testlib.AddInitialObjects(initialObjects, commonApiClient); err != nil {
+				t.Fatalf("error adding initial objects: %v", err)
+			}
+			testlib.WaitForSync(commonApiClient, stopCh)
+
+			ctrl := newVsphereController(commonApiClient)
+
+			conn, cleanup, err := setupSimulator(&simulator.Model{Datacenter: test.datacenterCount})
+			if err != nil {
+				t.Fatalf("failed to set up simulator: %v", err)
+			}
+			defer cleanup()
+			customizeVCenterVersion("7.0.2", "7.0.2", conn)
+
+			if err := customizeTopologyTags(context.TODO(), conn, test.tagsByDatacenter); err != nil {
+				t.Fatalf("failed to tag datacenters: %v", err)
+			}
+
+			ctrl.vsphereConnectionFunc = makeVsphereConnectionFunc(conn, false, nil)
+			if err := setHardwareVersionsFunc(nodes, conn, []string{"vmx-15", "vmx-15"})(); err != nil {
+				t.Fatalf("error setting hardware version: %v", err)
+			}
+
+			if err := ctrl.sync(context.TODO(), factory.NewSyncContext("vsphere-controller", ctrl.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error that could degrade cluster: %v", err)
+			}
+
+			if ctrl.operandControllerStarted != test.operandStarted {
+				t.Fatalf("expected operandStarted to be %v, got %v", test.operandStarted, ctrl.operandControllerStarted)
+			}
+
+			if test.expectedMetrics != "" {
+				if err := testutil.CollectAndCompare(utils.InstallErrorMetric, strings.NewReader(metricsHeader+test.expectedMetrics+"\n"), utils.InstallErrorMetric.Name); err != nil {
+					t.Errorf("wrong metrics: %s", err)
+				}
+			}
+		})
+	}
+}